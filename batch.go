@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/lunchboxer/markdowntoword/mdword"
+)
+
+// conversion is the outcome of converting one file of an -input-dir batch.
+type conversion struct {
+	input, output string
+	err           error
+}
+
+// convertDir converts every .md file of inputDir with the template into a .docx file of the
+// same name in outputDir, running up to jobs conversions at a time. A file which fails is
+// reported and skipped; a summary of all files is printed at the end and the number of
+// failures returned.
+func convertDir(conv *mdword.Converter, templateFile, inputDir, outputDir string, jobs int, defaults, sets map[string]string) (int, error) {
+	inputs, err := filepath.Glob(filepath.Join(inputDir, "*.md"))
+	if err != nil {
+		return 0, err
+	}
+	if len(inputs) == 0 {
+		return 0, fmt.Errorf("no .md files in %s", inputDir)
+	}
+	sort.Strings(inputs)
+
+	results := make([]conversion, len(inputs))
+	for i, input := range inputs {
+		results[i] = conversion{input: input, output: defaultOutput(input, outputDir, ".docx")}
+	}
+
+	if jobs < 1 {
+		jobs = 1
+	}
+	next := make(chan *conversion)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range next {
+				c.err = conv.ConvertFile(c.input, templateFile, c.output, defaults, sets)
+				if c.err != nil {
+					conv.Errorf("%s: %v", c.input, c.err)
+				} else {
+					written(c.output)
+				}
+			}
+		}()
+	}
+	for i := range results {
+		next <- &results[i]
+	}
+	close(next)
+	wg.Wait()
+
+	failed := 0
+	for _, c := range results {
+		if c.err != nil {
+			failed++
+			fmt.Fprintf(out, "FAIL %s: %v\n", c.input, c.err)
+		} else {
+			fmt.Fprintf(out, "ok   %s -> %s\n", c.input, c.output)
+		}
+	}
+	fmt.Fprintf(out, "Converted %d of %d files\n", len(results)-failed, len(results))
+	return failed, nil
+}
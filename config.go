@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// loadConfig reads the flag values of a -config file keyed by flag name without the dash.
+// A .json file holds an object, any other file TOML key = value lines; strings, numbers,
+// booleans and arrays are supported, the values of an array are given to a repeatable flag
+// one by one.
+func loadConfig(path string) (map[string][]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return jsonConfig(path, content)
+	}
+	return tomlConfig(path, content)
+}
+
+// jsonConfig parses a JSON config file. Numbers are kept as written, so that 1000000 is
+// not given to a flag as 1e+06.
+func jsonConfig(path string, content []byte) (map[string][]string, error) {
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON in %s: unexpected data after the object", path)
+	}
+	config := make(map[string][]string, len(raw))
+	for name, value := range raw {
+		values, ok := value.([]interface{})
+		if !ok {
+			values = []interface{}{value}
+		}
+		for _, v := range values {
+			switch v.(type) {
+			case string, json.Number, bool:
+				config[name] = append(config[name], fmt.Sprint(v))
+			default:
+				return nil, fmt.Errorf("value of %q in %s must be a string, number, boolean or array of them", name, path)
+			}
+		}
+	}
+	return config, nil
+}
+
+// tomlConfig parses the subset of TOML config files use: key = value lines without tables,
+// with # comments.
+func tomlConfig(path string, content []byte) (map[string][]string, error) {
+	config := make(map[string][]string)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("%s:%d: tables are not supported, give the flags at the top level", path, n)
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name = strings.Trim(strings.TrimSpace(name), `"`)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s:%d: expected name = value, got %q", path, n, line)
+		}
+		values, err := tomlValues(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		config[name] = values
+	}
+	return config, scanner.Err()
+}
+
+// tomlValues parses a TOML value and an optional trailing comment, returning the elements
+// of an array or the value itself.
+func tomlValues(s string) ([]string, error) {
+	var values []string
+	var err error
+	if strings.HasPrefix(s, "[") {
+		s = strings.TrimSpace(s[1:])
+		for !strings.HasPrefix(s, "]") {
+			var value string
+			if value, s, err = tomlScalar(s); err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			s = strings.TrimSpace(s)
+			if strings.HasPrefix(s, ",") {
+				s = strings.TrimSpace(s[1:])
+			} else if !strings.HasPrefix(s, "]") {
+				return nil, fmt.Errorf("expected , or ] in array, got %q", s)
+			}
+		}
+		s = s[1:]
+	} else {
+		var value string
+		if value, s, err = tomlScalar(s); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	if s = strings.TrimSpace(s); s != "" && !strings.HasPrefix(s, "#") {
+		return nil, fmt.Errorf("unexpected %q after the value", s)
+	}
+	return values, nil
+}
+
+// tomlScalar parses the string, number or boolean at the start of s and returns it with the
+// rest of s.
+func tomlScalar(s string) (value, rest string, err error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		quoted, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", fmt.Errorf("unterminated string %s", s)
+		}
+		value, err = strconv.Unquote(quoted)
+		return value, s[len(quoted):], err
+	case strings.HasPrefix(s, "'"):
+		// literal strings have no escapes
+		end := strings.Index(s[1:], "'")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	end := strings.IndexAny(s, ",]# \t")
+	if end < 0 {
+		end = len(s)
+	}
+	if value = s[:end]; value == "" {
+		return "", "", fmt.Errorf("missing value")
+	}
+	if value != "true" && value != "false" {
+		if _, err := strconv.ParseFloat(strings.ReplaceAll(value, "_", ""), 64); err != nil {
+			return "", "", fmt.Errorf("strings must be quoted, got %s", value)
+		}
+	}
+	return value, s[end:], nil
+}
+
+// applyConfig sets the flags of the -config file at path which were not given on the
+// command line, so that the command line overrides the file.
+func applyConfig(path string) error {
+	config, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	given := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" || flag.Lookup(name) == nil {
+			return fmt.Errorf("%s: unknown flag %q", path, name)
+		}
+		if given[name] {
+			continue
+		}
+		for _, value := range config[name] {
+			if err := flag.Set(name, value); err != nil {
+				return fmt.Errorf("%s: -%s: %v", path, name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -2,9 +2,14 @@ module github.com/lunchboxer/markdowntoword
 
 go 1.21
 
-require github.com/lukasjarosch/go-docx v0.4.7
+require (
+	github.com/alecthomas/chroma/v2 v2.15.0
+	github.com/lukasjarosch/go-docx v0.4.7
+)
+
+require github.com/dlclark/regexp2 v1.11.4 // indirect
 
 require (
-	golang.org/x/net v0.0.0-20200925080053-05aa5d4ee321 // indirect
+	golang.org/x/net v0.0.0-20200925080053-05aa5d4ee321
 	golang.org/x/text v0.16.0 // direct
 )
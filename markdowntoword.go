@@ -3,182 +3,561 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
-	"unicode"
 
-	"github.com/lukasjarosch/go-docx"
-	"golang.org/x/text/cases"
+	"github.com/lunchboxer/markdowntoword/mdword"
 )
 
-var verbose bool
+var (
+	// printPath makes the path of each written document the only output on stdout.
+	printPath bool
+	// printStatistics prints the -stats of the conversion once it is done.
+	printStatistics bool
 
-func sanitizeKey(s string) string {
-	// Use Unicode-aware case folding
-	caser := cases.Fold()
-	s = caser.String(s)
+	// out receives the messages of the command.
+	out io.Writer = os.Stdout
 
-	return strings.Map(func(r rune) rune {
-		if unicode.IsLetter(r) || unicode.IsNumber(r) || r == ' ' || r == '_' || r == '-' {
-			return r
-		}
-		return -1
-	}, s)
+	// reporter reports the warnings and errors of the run and keeps its statistics, it is
+	// nil until the options are validated. setupErrors counts the errors reported before.
+	reporter    *mdword.Converter
+	setupErrors int
+)
+
+// keyValueFlags collects repeated key=value flags.
+type keyValueFlags map[string]string
+
+func (f keyValueFlags) String() string {
+	var pairs []string
+	for key, value := range f {
+		pairs = append(pairs, key+"="+value)
+	}
+	return strings.Join(pairs, ",")
 }
 
-func parseMarkdown(markdownFile string) map[string]string {
-	content, err := os.ReadFile(markdownFile)
-	if err != nil {
-		panic(err)
+func (f keyValueFlags) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected key=value, got %q", s)
 	}
+	f[key] = value
+	return nil
+}
 
-	markdown := string(content)
-	lines := strings.Split(markdown, "\n")
+// fileListFlags collects the paths of a flag given repeatedly or as a comma-separated list.
+type fileListFlags []string
 
-	data := make(map[string]string)
-	currentPrefix := ""
-	currentKey := ""
-	currentValue := ""
-	previousLine := ""
+func (f *fileListFlags) String() string {
+	return strings.Join(*f, ",")
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+func (f *fileListFlags) Set(s string) error {
+	for _, path := range strings.Split(s, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			*f = append(*f, path)
+		}
+	}
+	return nil
+}
 
-		if strings.HasPrefix(line, "###") {
-			// Third-level heading
-			if verbose {
-				fmt.Println("Found heading: " + line)
-			}
-			heading := strings.TrimPrefix(line, "###")
-			key := sanitizeKey(heading)
-			if verbose {
-				fmt.Println("Sanitized key: " + key)
-			}
-			key = strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(key), " ", "-"), "_", "-"))
-			if verbose {
-				fmt.Println("key to kebab case: " + key)
-			}
-			if currentPrefix != "" {
-				key = currentPrefix + "-" + key
-			}
+// fail reports an error and ends the run with exit status 1.
+func fail(format string, args ...interface{}) {
+	if reporter != nil {
+		reporter.Errorf(format, args...)
+	} else {
+		setupErrors++
+		fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+	}
+	exit(1)
+}
 
-			if currentKey != "" {
-				data[currentKey] = strings.TrimSpace(processValue(currentValue))
-			}
+// runStats returns the totals of the run.
+func runStats() mdword.Counts {
+	if reporter == nil {
+		return mdword.Counts{Errors: setupErrors}
+	}
+	return reporter.Stats()
+}
 
-			currentKey = key
-			currentValue = ""
-		} else if strings.HasPrefix(line, ":") {
-			// Definition list item
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) == 2 {
-				key := sanitizeKey(previousLine)
-				key = strings.ReplaceAll(strings.ReplaceAll(string(key), " ", "-"), "_", "-")
-				value := strings.TrimSpace(parts[1])
-				if currentPrefix != "" {
-					key = currentPrefix + "-" + key
-				}
-				data[key] = value
-			}
-		} else if strings.HasPrefix(line, "##") {
-			// Second-level heading
-			if currentKey != "" {
-				data[currentKey] = strings.TrimSpace(processValue(currentValue))
-			}
-			currentKey = ""
-			currentValue = ""
+// written reports the path of a document written with -print-path.
+func written(path string) {
+	if printPath && path != "-" {
+		fmt.Println(path)
+	}
+}
 
-			currentPrefix = sanitizeKey(line)
-			currentPrefix = strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(strings.TrimPrefix(line, "##")), " ", "-"), "_", "-"))
-		} else if currentKey != "" {
-			// Append line to current value
-			currentValue += line + "\n"
+// parseArgs parses the command line flags and returns the positional arguments. Unlike
+// flag.Parse it also parses the flags following a positional argument, so that
+// -redline old.md new.md -template t.docx works; arguments after -- are all positional.
+func parseArgs() []string {
+	flag.Parse()
+	var args []string
+	for flag.NArg() > 0 {
+		rest := flag.Args()
+		if i := len(os.Args) - len(rest) - 1; i > 0 && os.Args[i] == "--" {
+			return append(args, rest...)
 		}
+		args = append(args, rest[0])
+		// Parse only fails on flags it exits for, as the command line uses ExitOnError
+		flag.CommandLine.Parse(rest[1:])
+	}
+	return args
+}
 
-		previousLine = line
+func main() {
+	opts := mdword.DefaultOptions()
+	var markdownFiles fileListFlags
+	flag.Var(&markdownFiles, "markdown", "Path to the markdown file, - to read it from stdin. Repeat the flag or separate paths with commas to merge several files, later files overriding keys of earlier ones")
+	templateFile := flag.String("template", "", "Path to the Word document template")
+	outputFile := flag.String("output", "", "Path to the output Word document (optional), - to write it to stdout")
+	flag.BoolVar(&opts.Verbose, "v", false, "Enable verbose output")
+	flag.BoolVar(&opts.AllowHTMLTables, "allow-html-tables", false, "Render inline HTML tables in values as Word tables")
+	flag.BoolVar(&opts.AllowColor, "allow-color", false, "Color text in {color:red}…{color} and [red]{…} spans")
+	flag.StringVar(&opts.Bullet, "bullet", opts.Bullet, "Marker written for the items of - and + bullet lists")
+	flag.StringVar(&opts.OpenDelim, "open-delim", opts.OpenDelim, "Character opening the placeholders of the template")
+	flag.StringVar(&opts.CloseDelim, "close-delim", opts.CloseDelim, "Character closing the placeholders of the template")
+	flag.StringVar(&opts.HorizontalRule, "horizontal-rule", opts.HorizontalRule, "What ---, *** and ___ lines in values become: drop or page (a page break)")
+	flag.BoolVar(&opts.Cover, "cover", false, "Start the document with a cover page of the title, subtitle, author, date and logo keys, e.g. from the front matter")
+	flag.BoolVar(&opts.TOC, "toc", false, "Fill the {toc} placeholder with the ## and deeper headings of the markdown, indented by level")
+	flag.BoolVar(&opts.SmartyPants, "smartypants", false, "Write curly quotes, -- as an en dash, --- as an em dash and ... as an ellipsis, leaving code alone")
+	flag.BoolVar(&opts.Renumber, "renumber", false, "Renumber ordered list items from 1 instead of keeping the numbers of the markdown")
+	flag.BoolVar(&opts.KeepTrailingBlank, "keep-trailing-blank", false, "Keep a trailing blank line of a value as an empty paragraph")
+	flag.StringVar(&opts.KeyStyle, "key-style", opts.KeyStyle, "How heading keys are derived: text or ordinal")
+	flag.BoolVar(&opts.KeyIncludeLevel, "key-include-level", false, "Prefix heading keys with their level, e.g. h2-overview-h3-summary, so equal headings at different levels cannot collide")
+	flag.BoolVar(&opts.PreserveCase, "preserve-case", false, "Keep the case of heading and term text in keys, e.g. Project-Name, instead of lower casing them")
+	flag.StringVar(&opts.OrdinalScope, "ordinal-scope", opts.OrdinalScope, "Numbering of ordinal keys: global or level")
+	flag.StringVar(&opts.ParagraphStyle, "paragraph-style", "", "Word style ID applied to paragraphs of substituted values")
+	flag.StringVar(&opts.ListStyle, "list-style", "", "Word style ID applied to list items of substituted values")
+	flag.StringVar(&opts.CodeStyle, "code-style", "", "Word style ID applied to fenced code lines of substituted values")
+	flag.StringVar(&opts.QuoteStyle, "quote-style", "", "Word style ID applied to blockquote paragraphs of substituted values instead of italics")
+	flag.BoolVar(&opts.Highlight, "highlight", false, "Render fenced code blocks in a monospaced font with syntax coloring for their language")
+	dataFile := flag.String("data-json", "", "Path to a JSON file with the placeholder values, used instead of parsing markdown")
+	keyMapFile := flag.String("keymap", "", "Path to a file mapping data keys to template placeholders, key = placeholder per line or a JSON object")
+	defaultsFile := flag.String("defaults", "", "Path to a JSON file with default placeholder values")
+	sets := keyValueFlags{}
+	flag.Var(sets, "set", "Set a placeholder value as key=value, overriding the markdown (repeatable)")
+	inputDir := flag.String("input-dir", "", "Convert every .md file of this directory with the template")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "Number of -input-dir files converted at the same time")
+	outputDir := flag.String("output-dir", "", "Directory the documents of -input-dir, or a document without -output, are written to, the input directory by default")
+	mergeFile := flag.String("merge-data", "", "Path to a CSV or JSON array file; one document is generated per row")
+	outputPattern := flag.String("output-pattern", "", "Output path of merged documents, {n} is the row number and {column} a row value")
+	flag.BoolVar(&opts.DocVars, "docvars", false, "Also store the placeholder values as Word document variables for DOCVARIABLE fields")
+	docVarKeyList := flag.String("docvar-keys", "", "Comma separated keys stored as document variables, implies -docvars")
+	flag.BoolVar(&opts.CommentsAsWordComments, "comments-as-word-comments", false, "Turn HTML comments in values into Word comments on the following text")
+	flag.BoolVar(&opts.TableHeader, "table-header", opts.TableHeader, "Treat the first row of {{table: file.csv}} data as the header row")
+	flag.StringVar(&opts.TableAlign, "table-align", "", "Alignment of the {{table: file.csv}} columns, a letter per column of l, c or r")
+	flag.StringVar(&opts.Compression, "compression", "", "Zip compression of the written document: store, fast or best")
+	flag.StringVar(&opts.Math, "math", "", "Render inline $math$ spans without the dollar signs: strip, italic or mono")
+	flag.StringVar(&opts.UnbalancedEmphasis, "unbalanced-emphasis", "literal", "Handling of an emphasis marker which is never closed: literal, autoclose or warn")
+	flag.BoolVar(&opts.RTL, "rtl", false, "Write all substituted values right to left")
+	flag.StringVar(&opts.DocLang, "doc-lang", "", "Language of the document, e.g. en-US, set on substituted text and the document defaults")
+	flag.BoolVar(&opts.InteractiveCheckboxes, "interactive-checkboxes", false, "Render task list items as checkbox controls which can be toggled in Word")
+	flag.BoolVar(&printPath, "print-path", false, "Print only the path of the written document to stdout, everything else goes to stderr")
+	flag.IntVar(&opts.Columns, "columns", opts.Columns, "Number of columns of the section enclosing a value with column breaks, 1 to leave the layout alone")
+	stamp := flag.Bool("stamp-footer", false, "Replace the footer of every page with a generation stamp")
+	stampFormat := flag.String("footer-format", "Generated {date} from {source} - page {page}", "Text of the -stamp-footer footer; {date}, {source} and {page} are replaced")
+	emitData := flag.String("emit-data", "", "Write the final placeholder data as JSON to this file, - for stdout; without -template nothing else is done")
+	requiredFile := flag.String("require-placeholders", "", "Path to a list of placeholders the template must contain, one per line")
+	flag.BoolVar(&printStatistics, "stats", false, "Print the keys, filled and empty placeholders and words of the conversion to stderr")
+	pdf := flag.Bool("pdf", false, "Also convert the written document to PDF with LibreOffice; implied by an -output ending in .pdf")
+	dryRun := flag.Bool("dry-run", false, "Parse and fill the template and print the replacements without writing a document")
+	check := flag.Bool("check", false, "Lint the template placeholders against the parsed data instead of writing a document")
+	schemaFile := flag.String("schema", "", "Path to a JSON schema the parsed data is validated against")
+	flag.BoolVar(&opts.RequireReplacement, "require-replacement", false, "Fail if no placeholder of the template matches the data")
+	flag.BoolVar(&opts.AllowEmpty, "allow-empty", false, "Accept markdown which gives no keys instead of failing")
+	flag.BoolVar(&opts.Safe, "safe", false, "Do not read any file referenced by the markdown, for untrusted input")
+	flag.BoolVar(&opts.Strict, "strict", false, "Fail instead of only warning when validation finds problems")
+	flag.StringVar(&opts.StripLinePrefix, "strip-line-prefix", "", "Prefix removed from every value line, e.g. '> ' for quoted email text")
+	flag.StringVar(&opts.Encoding, "encoding", "", "Encoding of the markdown files, e.g. windows-1252 or utf-16le; UTF-8 by default")
+	stripTagList := flag.String("strip-tags", "", "Comma separated names of elements removed with their content, e.g. draft,internal")
+	listPlaceholders := flag.Bool("list-placeholders", false, "Print the placeholders of the -template sorted, one per line, and exit")
+	selftest := flag.Bool("selftest", false, "Convert a built-in sample document, check the result and exit")
+	flag.StringVar(&opts.ExplainKey, "explain", "", "Print how the value of this key was produced and exit")
+	redline := flag.Bool("redline", false, "Compare two markdown files given as arguments and render the differences as tracked changes")
+	flag.StringVar(&metricsFile, "metrics", "", "Write run metrics in Prometheus text format to this file")
+	configFile := flag.String("config", "", "Path to a JSON or TOML file of flag values, e.g. template = \"t.docx\"; flags given on the command line override it")
+	args := parseArgs()
+	if *configFile != "" {
+		if err := applyConfig(*configFile); err != nil {
+			fail("%v", err)
+		}
+	}
+	for _, path := range []*string{templateFile, outputFile, dataFile, keyMapFile, defaultsFile, inputDir, outputDir, mergeFile, emitData, requiredFile, schemaFile} {
+		*path = localPath(*path)
+	}
+	for i, path := range markdownFiles {
+		markdownFiles[i] = localPath(path)
 	}
+	defer flushMetrics()
 
-	// Handle the last heading or definition list item
-	if currentKey != "" {
-		data[currentKey] = strings.TrimSpace(processValue(currentValue))
+	// markdown piped in without -markdown is converted to stdout, for use in pipelines
+	if len(markdownFiles) == 0 && *dataFile == "" && *inputDir == "" && !*redline && *mergeFile == "" && !*selftest && !*listPlaceholders && stdinPiped() {
+		markdownFiles = fileListFlags{"-"}
 	}
-	if verbose {
-		fmt.Printf("data length is %d\n", len(data))
-		for key, value := range data {
-			fmt.Printf("%s: %s\n", key, value)
-		}
+	// markdownFile is the first markdown file, which names the output and resolves relative paths
+	var markdownFile string
+	if len(markdownFiles) > 0 {
+		markdownFile = markdownFiles[0]
+	}
+	if markdownFile == "-" && *outputFile == "" && *templateFile != "" {
+		*outputFile = "-"
 	}
 
-	return data
-}
+	if *emitData == "-" && *outputFile == "-" {
+		fail("-emit-data and -output cannot both write to stdout")
+	}
 
-func replaceMustacheTags(templateFile string, data map[string]string, outputFile string) {
-	if verbose {
-		fmt.Println("\nWill look for strings to replace now")
+	if printPath || *outputFile == "-" || *emitData == "-" {
+		out = os.Stderr
 	}
-	doc, err := docx.Open(templateFile)
-	if err != nil {
-		panic(err)
+
+	for _, name := range strings.Split(*stripTagList, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.StripTags = append(opts.StripTags, name)
+		}
 	}
 
-	replaceMap := docx.PlaceholderMap{}
-	for key, value := range data {
-		replaceMap[key] = value
+	for _, key := range strings.Split(*docVarKeyList, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			opts.DocVarKeys = append(opts.DocVarKeys, key)
+		}
 	}
 
-	for key, value := range replaceMap {
-		fmt.Printf("%s: %s\n", key, value)
+	if *stamp {
+		opts.FooterFormat = *stampFormat
 	}
 
-	err = doc.ReplaceAll(replaceMap)
-	if err != nil {
-		fmt.Printf("Error replacing placeholders: %v\n", err)
-	} else {
-		if verbose {
-			fmt.Println("Replacements completed successfully")
+	if *keyMapFile != "" {
+		keyMap, err := mdword.LoadKeyMap(*keyMapFile)
+		if err != nil {
+			fail("%v", err)
 		}
+		opts.KeyMap = keyMap
 	}
 
-	err = doc.WriteToFile(outputFile)
+	opts.Vars = sets
+	opts.Log = os.Stderr
+	conv, err := mdword.NewConverter(opts)
 	if err != nil {
-		panic(err)
+		fail("%v", err)
 	}
-}
+	reporter = conv
 
-func processValue(value string) string {
-	listItems := strings.Split(value, "\n")
-	var bulletPoints []string
-	for _, item := range listItems {
-		if strings.HasPrefix(item, "-") || strings.HasPrefix(item, "+") {
-			item = strings.Replace(item, string(item[0]), "•", 1)
+	if *selftest {
+		if err := runSelfTest(conv); err != nil {
+			fmt.Fprintf(out, "Self test failed: %v\n", err)
+			exit(1)
 		}
-		bulletPoints = append(bulletPoints, item)
+		fmt.Fprintln(out, "Self test passed")
+		return
 	}
-	return strings.Join(bulletPoints, "\n")
-}
 
-func main() {
-	markdownFile := flag.String("markdown", "", "Path to the markdown file")
-	templateFile := flag.String("template", "", "Path to the Word document template")
-	outputFile := flag.String("output", "", "Path to the output Word document (optional)")
-	flag.BoolVar(&verbose, "v", false, "Enable verbose output")
-	flag.Parse()
+	if *listPlaceholders {
+		if *templateFile == "" {
+			fail("-list-placeholders requires a -template")
+		}
+		placeholders, err := conv.TemplatePlaceholders(*templateFile)
+		if err != nil {
+			fail("%v", err)
+		}
+		printPlaceholders(os.Stdout, placeholders)
+		return
+	}
 
-	// Check if required arguments are provided
-	if *markdownFile == "" {
-		fmt.Println("Error: Markdown file path is required")
+	if *redline {
+		if len(args) != 2 || *templateFile == "" {
+			fail("-redline requires a template and the old and new markdown files, e.g. -redline -template t.docx old.md new.md")
+		}
+		oldFile, newFile := localPath(args[0]), localPath(args[1])
+		if *outputFile == "" {
+			*outputFile = defaultOutput(newFile, *outputDir, ".docx")
+		}
+		if err := checkNotInput(*outputFile, oldFile, newFile, *templateFile); err != nil {
+			fail("%v", err)
+		}
+		oldDoc, err := conv.ParseMarkdownFile(oldFile)
+		if err != nil {
+			fail("%v", err)
+		}
+		newDoc, err := conv.ParseMarkdownFile(newFile)
+		if err != nil {
+			fail("%v", err)
+		}
+		if err := conv.WriteRedline(*templateFile, oldDoc, newDoc, *outputFile); err != nil {
+			fail("%v", err)
+		}
+		written(*outputFile)
+		return
+	}
+
+	if *mergeFile != "" {
+		if *templateFile == "" {
+			fail("Template file path is required")
+		}
+		rows, err := mdword.LoadMergeRows(*mergeFile)
+		if err != nil {
+			fail("%v", err)
+		}
+		if *outputPattern == "" {
+			*outputPattern = defaultOutput(*mergeFile, *outputDir, "-{n}.docx")
+		}
+		for i, row := range rows {
+			path := mdword.MergeOutputPath(*outputPattern, i+1, row)
+			doc := &mdword.Document{Data: mdword.MergeData(row, sets), Source: *mergeFile}
+			if err := conv.RenderFile(doc, *templateFile, path); err != nil {
+				fail("%v", err)
+			}
+			written(path)
+		}
+		printStats()
 		return
 	}
-	if *templateFile == "" {
-		fmt.Println("Error: Template file path is required")
+
+	if *inputDir != "" {
+		if *templateFile == "" {
+			fail("Template file path is required")
+		}
+		if *outputDir == "" {
+			*outputDir = *inputDir
+		}
+		defaults := map[string]string{}
+		if *defaultsFile != "" {
+			var err error
+			defaults, err = mdword.LoadDataJSON(*defaultsFile)
+			if err != nil {
+				fail("%v", err)
+			}
+		}
+		failed, err := convertDir(conv, *templateFile, *inputDir, *outputDir, *jobs, defaults, sets)
+		if err != nil {
+			fail("%v", err)
+		}
+		printStats()
+		if failed > 0 {
+			exit(1)
+		}
 		return
 	}
 
+	// Check if required arguments are provided
+	if markdownFile != "" && *dataFile != "" {
+		fail("-markdown and -data-json cannot be used together")
+	}
+	if markdownFile == "" && *dataFile == "" {
+		fail("Markdown file path is required")
+	}
+	stdinFiles := 0
+	for _, path := range markdownFiles {
+		if path == "-" {
+			stdinFiles++
+		}
+	}
+	if stdinFiles > 1 {
+		fail("-markdown can read stdin only once")
+	}
+	if *templateFile == "" && *emitData == "" && opts.ExplainKey == "" {
+		fail("Template file path is required")
+	}
+
+	if *requiredFile != "" && *templateFile != "" {
+		required, err := conv.LoadPlaceholderList(*requiredFile)
+		if err != nil {
+			fail("%v", err)
+		}
+		placeholders, err := conv.TemplatePlaceholders(*templateFile)
+		if err != nil {
+			fail("%v", err)
+		}
+		missing := mdword.MissingPlaceholders(required, placeholders)
+		if len(missing) > 0 {
+			fail("template %s is missing required placeholders: %s", *templateFile, strings.Join(missing, ", "))
+		}
+	}
+
 	// Set default output file path if not provided
 	if *outputFile == "" {
-		*outputFile = strings.TrimSuffix(*markdownFile, filepath.Ext(*markdownFile)) + ".docx"
+		input := markdownFile
+		if *dataFile != "" {
+			input = *dataFile
+		}
+		*outputFile = defaultOutput(input, *outputDir, ".docx")
+	}
+	defaults := map[string]string{}
+	if *defaultsFile != "" {
+		var err error
+		defaults, err = mdword.LoadDataJSON(*defaultsFile)
+		if err != nil {
+			fail("%v", err)
+		}
+	}
+	var doc *mdword.Document
+	switch {
+	case *dataFile != "":
+		doc = &mdword.Document{Source: *dataFile}
+		doc.Data, err = mdword.LoadDataJSON(*dataFile)
+	case len(markdownFiles) > 1:
+		doc, err = conv.ParseMarkdownFiles(markdownFiles)
+	case markdownFile == "-":
+		if doc, err = conv.ParseMarkdown(os.Stdin); err == nil {
+			doc.Source = "stdin"
+		}
+	default:
+		doc, err = conv.ParseMarkdownFile(markdownFile)
+	}
+	if err != nil {
+		fail("%v", err)
+	}
+	data := mdword.MergeData(defaults, doc.Data, sets)
+
+	if opts.ExplainKey != "" {
+		doc.Explain(out, opts.ExplainKey, defaults, sets, data)
+		return
+	}
+	doc.Data = data
+
+	if *schemaFile != "" {
+		schema, err := mdword.LoadSchema(*schemaFile)
+		if err != nil {
+			fail("%v", err)
+		}
+		violations := schema.Validate(data)
+		for _, violation := range violations {
+			conv.Warnf("schema: %s", violation)
+		}
+		if opts.Strict && len(violations) > 0 {
+			fail("data does not match schema %s", *schemaFile)
+		}
+	}
+
+	if *emitData != "" {
+		if *emitData == "-" {
+			err = mdword.EncodeDataJSON(os.Stdout, data)
+		} else {
+			err = mdword.WriteDataJSON(*emitData, data)
+		}
+		if err != nil {
+			fail("%v", err)
+		}
+		if *templateFile == "" {
+			return
+		}
+	}
+
+	if *check {
+		placeholders, err := conv.TemplatePlaceholders(*templateFile)
+		if err != nil {
+			fail("%v", err)
+		}
+		problems := conv.CheckTemplate(placeholders, data)
+		for _, problem := range problems {
+			conv.Warnf("%s", problem)
+		}
+		if len(problems) > 0 {
+			exit(1)
+		}
+		fmt.Fprintln(out, "Template check passed")
+		return
+	}
+
+	if *dryRun {
+		placeholders, err := conv.TemplatePlaceholders(*templateFile)
+		if err != nil {
+			fail("%v", err)
+		}
+		printReplacements(conv, placeholders, data)
+		if err := conv.Render(doc, *templateFile, io.Discard); err != nil {
+			fail("%v", err)
+		}
+		fmt.Fprintln(out, "Dry run passed, no document written")
+		return
+	}
+
+	// a .pdf output is converted from the .docx document of the same name
+	converter := ""
+	if strings.EqualFold(filepath.Ext(*outputFile), ".pdf") {
+		*pdf = true
+		*outputFile = withExt(*outputFile, ".docx")
 	}
-	data := parseMarkdown(*markdownFile)
-	replaceMustacheTags(*templateFile, data, *outputFile)
+	if *pdf {
+		if *outputFile == "-" {
+			fail("-pdf cannot write to stdout, give an -output file")
+		}
+		if opts.Safe {
+			fail("-pdf runs LibreOffice and cannot be used with -safe")
+		}
+		if converter, err = findPDFConverter(); err != nil {
+			fail("%v", err)
+		}
+	}
+	if err := checkNotInput(*outputFile, append([]string{*dataFile, *templateFile, *defaultsFile}, markdownFiles...)...); err != nil {
+		fail("%v", err)
+	}
+
+	if *outputFile == "-" {
+		err = conv.Render(doc, *templateFile, os.Stdout)
+	} else {
+		err = conv.RenderFile(doc, *templateFile, *outputFile)
+	}
+	if err != nil {
+		fail("%v", err)
+	}
+	written(*outputFile)
+	printStats()
+	if *pdf {
+		pdfFile, err := convertToPDF(converter, *outputFile)
+		if err != nil {
+			fail("%v", err)
+		}
+		written(pdfFile)
+	}
+}
+
+// printStats prints the statistics of the documents written with -stats.
+func printStats() {
+	if !printStatistics {
+		return
+	}
+	t := runStats()
+	fmt.Fprintf(os.Stderr, "Keys: %d\n", t.Keys)
+	fmt.Fprintf(os.Stderr, "Placeholders filled: %d, left empty: %d\n", t.Placeholders, t.Unfilled)
+	fmt.Fprintf(os.Stderr, "Words of filled content: %d\n", t.Words)
+}
+
+// printPlaceholders lists the placeholder keys of a template sorted, each once, in the
+// format of -require-placeholders files.
+func printPlaceholders(w io.Writer, placeholders []string) {
+	sorted := append([]string(nil), placeholders...)
+	sort.Strings(sorted)
+	for i, key := range sorted {
+		if i == 0 || key != sorted[i-1] {
+			fmt.Fprintln(w, key)
+		}
+	}
+}
+
+// printReplacements lists the value each placeholder of the template would be replaced
+// with, in template order.
+func printReplacements(conv *mdword.Converter, placeholders []string, data map[string]string) {
+	seen := make(map[string]bool)
+	for _, key := range placeholders {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if value, ok := data[key]; ok {
+			fmt.Fprintf(out, "%s <- %q\n", conv.Placeholder(key), value)
+		} else {
+			fmt.Fprintf(out, "%s <- (no value)\n", conv.Placeholder(key))
+		}
+	}
+}
+
+// stdinPiped reports whether stdin is a pipe or file rather than a terminal.
+func stdinPiped() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
 }
@@ -0,0 +1,2479 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lukasjarosch/go-docx"
+	"github.com/lunchboxer/markdowntoword/mdword"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata/parse")
+
+// TestMain runs the command in place of the tests when the test binary is started by
+// runCommand.
+func TestMain(m *testing.M) {
+	if os.Getenv("MARKDOWNTOWORD_RUN_MAIN") == "1" {
+		main()
+		exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCommand runs the command with args in dir and returns its stdout, stderr and exit
+// status. env is added to the environment of the command.
+func runCommand(t *testing.T, dir string, env []string, args ...string) (stdout, stderr string, code int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "MARKDOWNTOWORD_RUN_MAIN=1"), env...)
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &outBuf, &errBuf
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return outBuf.String(), errBuf.String(), code
+}
+
+// converter returns a Converter with the default options changed by configure.
+func converter(t *testing.T, configure func(*mdword.Options)) *mdword.Converter {
+	t.Helper()
+	opts := mdword.DefaultOptions()
+	if configure != nil {
+		configure(&opts)
+	}
+	c, err := mdword.NewConverter(opts)
+	if err != nil {
+		t.Fatalf("NewConverter: %v", err)
+	}
+	return c
+}
+
+// parse parses markdown with the default options changed by configure.
+func parse(t *testing.T, markdown string, configure func(*mdword.Options)) map[string]string {
+	t.Helper()
+	doc, err := converter(t, configure).ParseMarkdown(strings.NewReader(markdown))
+	if err != nil {
+		t.Fatalf("ParseMarkdown: %v", err)
+	}
+	return doc.Data
+}
+
+// convert parses markdown with the default options changed by configure and renders it into
+// a template holding text, returning the path of the document written.
+func convert(t *testing.T, markdown, text string, configure func(*mdword.Options)) string {
+	t.Helper()
+	c := converter(t, configure)
+	doc, err := c.ParseMarkdown(strings.NewReader(markdown))
+	if err != nil {
+		t.Fatalf("ParseMarkdown: %v", err)
+	}
+	dir := t.TempDir()
+	templateFile := filepath.Join(dir, "template.docx")
+	outputFile := filepath.Join(dir, "output.docx")
+	if err := writeSelfTestTemplate(templateFile, text); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RenderFile(doc, templateFile, outputFile); err != nil {
+		t.Fatalf("RenderFile: %v", err)
+	}
+	return outputFile
+}
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name      string
+		markdown  string
+		configure func(*mdword.Options)
+		want      map[string]string
+	}{
+		{
+			name:     "heading key",
+			markdown: "## Section\n\n### Start  Date \n\nvalue\n",
+			want:     map[string]string{"section-start-date": "value"},
+		},
+		{
+			name:     "punctuation dropped",
+			markdown: "## Notes\n\n### What's new? (v2.0)\n\nvalue\n",
+			want:     map[string]string{"notes-whats-new-v20": "value"},
+		},
+		{
+			name:     "underscores and dashes collapse",
+			markdown: "### snake_case -- and  dashes\n\nvalue\n",
+			want:     map[string]string{"snake-case-and-dashes": "value"},
+		},
+		{
+			name:     "separators collapse",
+			markdown: "## Big  Section \n\n### Start\t\tDate \t\n\nv\n\n### - _Draft_ Notes -\n\nn\n",
+			want:     map[string]string{"big-section-start-date": "v", "big-section-draft-notes": "n"},
+		},
+		{
+			name:     "term separators collapse",
+			markdown: "## Big  Section\n\nLead  Time\t\n: t\n",
+			want:     map[string]string{"big-section-lead-time": "t"},
+		},
+		{
+			name:     "no prefix without second-level heading",
+			markdown: "### Alone\n\nvalue\n",
+			want:     map[string]string{"alone": "value"},
+		},
+		{
+			name:     "empty values",
+			markdown: "## S\n\n### First\n### Second\n\n\n### Third\n\nx\n",
+			want:     map[string]string{"s-first": "", "s-second": "", "s-third": "x"},
+		},
+		{
+			name:     "prefix changes with section",
+			markdown: "## One\n\n### Key\n\na\n\n## Two\n\n### Key\n\nb\n",
+			want:     map[string]string{"one-key": "a", "two-key": "b"},
+		},
+		{
+			name:     "deeper headings nest",
+			markdown: "## A\n\n### B\n\nb\n\n#### C\n\nc\n\n##### D\n\nd\n\n#### E\n\ne\n",
+			want:     map[string]string{"a-b": "b", "a-b-c": "c", "a-b-c-d": "d", "a-b-e": "e"},
+		},
+		{
+			name:     "title heading resets prefix",
+			markdown: "## A\n\n### B\n\nb\n\n# Title\n\n### C\n\nc\n",
+			want:     map[string]string{"a-b": "b", "c": "c"},
+		},
+		{
+			name:      "key include level",
+			markdown:  "## A\n\n### B\n\nb\n",
+			configure: func(o *mdword.Options) { o.KeyIncludeLevel = true },
+			want:      map[string]string{"h2-a-h3-b": "b"},
+		},
+		{
+			name:      "key include level same headings",
+			markdown:  "### Overview\n\nthree\n\n#### Overview\n\nfour\n\n### Intro Details\n\ndetails\n\n### Intro\n\n#### Details\n\nnested\n",
+			configure: func(o *mdword.Options) { o.KeyIncludeLevel = true },
+			want: map[string]string{
+				"h3-overview":             "three",
+				"h3-overview-h4-overview": "four",
+				"h3-intro-details":        "details",
+				"h3-intro":                "",
+				"h3-intro-h4-details":     "nested",
+			},
+		},
+		{
+			name:     "same headings without level collide",
+			markdown: "### Intro Details\n\ndetails\n\n### Intro\n\n#### Details\n\nnested\n",
+			want:     map[string]string{"intro-details": "nested", "intro": ""},
+		},
+		{
+			name:      "ordinal keys",
+			markdown:  "## A\n\n### B\n\nb\n\n### C\n\nc\n\n## D\n\n### E\n\ne\n",
+			configure: func(o *mdword.Options) { o.KeyStyle = "ordinal" },
+			want:      map[string]string{"section-1": "b", "section-2": "c", "section-3": "e"},
+		},
+		{
+			name:      "ordinal keys per level",
+			markdown:  "## A\n\n### B\n\nb\n\n## D\n\n### E\n\ne\n",
+			configure: func(o *mdword.Options) { o.KeyStyle, o.OrdinalScope = "ordinal", "level" },
+			want:      map[string]string{"section-1-1": "b", "section-2-1": "e"},
+		},
+		{
+			name:     "definition list",
+			markdown: "## Terms\n\nAPI\n: Application programming interface\n\nRest Call\n:   a request\n",
+			want:     map[string]string{"terms-api": "Application programming interface", "terms-rest-call": "a request"},
+		},
+		{
+			name:     "one line definitions keep colons",
+			markdown: "## Meeting\n\nStart : 10:30\nLink : https://example.com/a:b\n",
+			want:     map[string]string{"meeting-start": "10:30", "meeting-link": "https://example.com/a:b"},
+		},
+		{
+			name:     "one line definition form is prose within values",
+			markdown: "### Ratio\n\nwidth : height\n",
+			want:     map[string]string{"ratio": "width : height"},
+		},
+		{
+			name:     "multi-line definition",
+			markdown: "## Terms\n\nAPI\n: Application   programming\n  interface for  callers\n  of the service\nNext\n: n\n",
+			want:     map[string]string{"terms-api": "Application programming\ninterface for callers\nof the service", "terms-next": "n"},
+		},
+		{
+			name:     "definition with tab indentation",
+			markdown: "Owner\n:\tJane\t\tDoe\n\tTeam\tlead\n\nUnindented\n",
+			want:     map[string]string{"owner": "Jane Doe\nTeam lead"},
+		},
+		{
+			name:     "one line definition whitespace collapsed",
+			markdown: "Start : 10:30   sharp\n",
+			want:     map[string]string{"start": "10:30 sharp"},
+		},
+		{
+			name:     "definition under heading joins its value",
+			markdown: "### Term\n: meaning\n",
+			want:     map[string]string{"term": "meaning"},
+		},
+		{
+			name:     "trailing blank trimmed",
+			markdown: "### A\n\na\n\n\n### B\n\nb\n",
+			want:     map[string]string{"a": "a", "b": "b"},
+		},
+		{
+			name:      "keep trailing blank",
+			markdown:  "### A\n\na\n\n\n### B\n\nb\n\n",
+			configure: func(o *mdword.Options) { o.KeepTrailingBlank = true },
+			want:      map[string]string{"a": "a\n", "b": "b\n"},
+		},
+		{
+			name:      "keep trailing blank needs a blank line",
+			markdown:  "### A\n\na\n### B\n\n\n",
+			configure: func(o *mdword.Options) { o.KeepTrailingBlank = true },
+			want:      map[string]string{"a": "a", "b": ""},
+		},
+		{
+			name:      "strip multi-line tag",
+			markdown:  "### Notes\n\nkept\n<draft>\n### Hidden\n\nsecret\n</draft>\nalso kept\n",
+			configure: func(o *mdword.Options) { o.StripTags = []string{"draft"} },
+			want:      map[string]string{"notes": "kept\nalso kept"},
+		},
+		{
+			name:      "strip nested and inline tags",
+			markdown:  "### Notes\n\na <draft>x <draft>y</draft> z</draft>b <internal note=\"1\">c</internal>\n",
+			configure: func(o *mdword.Options) { o.StripTags = []string{"draft", "internal"} },
+			want:      map[string]string{"notes": "a b"},
+		},
+		{
+			name:      "unlisted tags kept",
+			markdown:  "### Notes\n\n<review>r</review>\n",
+			configure: func(o *mdword.Options) { o.StripTags = []string{"draft"} },
+			want:      map[string]string{"notes": "<review>r</review>"},
+		},
+		{
+			name:      "strip line prefix",
+			markdown:  "## Mail\n\nTerm\n: > quoted definition\n\n### Reply\n\n> On Monday you wrote:\n>\n>   - first point\n> second line\nunquoted\n",
+			configure: func(o *mdword.Options) { o.StripLinePrefix = "> " },
+			want:      map[string]string{"mail-reply": "On Monday you wrote:\n\n• first point\nsecond line\nunquoted", "mail-term": "quoted definition"},
+		},
+		{
+			name:     "line prefix kept by default",
+			markdown: "### Reply\n\n> quoted\n",
+			want:     map[string]string{"reply": "> quoted"},
+		},
+		{
+			name:     "bullets rewritten",
+			markdown: "### List\n\n- dash\n+ plus\n* star\n",
+			want:     map[string]string{"list": "• dash\n• plus\n* star"},
+		},
+		{
+			name:      "custom bullet",
+			markdown:  "### List\n\n- a\n- b\n",
+			configure: func(o *mdword.Options) { o.Bullet = "–" },
+			want:      map[string]string{"list": "– a\n– b"},
+		},
+		{
+			name:     "ordered items keep their numbers",
+			markdown: "### Steps\n\n3) three\n7. seven\n",
+			want:     map[string]string{"steps": "3. three\n7. seven"},
+		},
+		{
+			name:      "ordered items renumbered",
+			markdown:  "### Steps\n\n3) three\n7. seven\n",
+			configure: func(o *mdword.Options) { o.Renumber = true },
+			want:      map[string]string{"steps": "1. three\n2. seven"},
+		},
+		{
+			name:     "code and rules are no list items",
+			markdown: "### Code\n\n```\n- kept\n```\n---\n",
+			want:     map[string]string{"code": "```\n- kept\n```\n---"},
+		},
+		{
+			name:     "pipe table separator kept",
+			markdown: "### Table\n\n| a | b |\n|---|---|\n| 1 | 2 |\n",
+			want:     map[string]string{"table": "| a | b |\n|---|---|\n| 1 | 2 |"},
+		},
+		{
+			name:     "unicode keys folded",
+			markdown: "## Größe\n\n### Ünïcödé Straße\n\nx\n\n### 日本語 見出し\n\ny\n",
+			want:     map[string]string{"grösse-ünïcödé-strasse": "x", "grösse-日本語-見出し": "y"},
+		},
+		{
+			name:      "preserve case",
+			markdown:  "## Intro\n\n### Project Name\n\nx\n",
+			configure: func(o *mdword.Options) { o.PreserveCase = true },
+			want:      map[string]string{"Intro-Project-Name": "x"},
+		},
+		{
+			name:     "windows line endings",
+			markdown: "## A\r\n\r\n### B\r\n\r\none\r\ntwo\r\n",
+			want:     map[string]string{"a-b": "one\ntwo"},
+		},
+		{
+			name:     "front matter overridden by body",
+			markdown: "---\ntitle: 'It''s'\nversion: 1.2 # draft\nb: front\n---\n### B\n\nbody\n",
+			want:     map[string]string{"title": "It's", "version": "1.2", "b": "body"},
+		},
+		{
+			name:     "setext headings",
+			markdown: "Part\n====\n\nSection\n-------\n\n### Key\n\nv\n",
+			want:     map[string]string{"section-key": "v"},
+		},
+		{
+			name:     "hash tags are no headings",
+			markdown: "### Tags\n\n#one #two\n",
+			want:     map[string]string{"tags": "#one #two"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parse(t, tt.markdown, tt.configure)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMarkdownDuplicateKeys(t *testing.T) {
+	markdown := "### Start Date\n\na\n\n### Start Date\n\nb\n"
+	if got := parse(t, markdown, nil); got["start-date"] != "b" {
+		t.Errorf("got %q, want the later value", got["start-date"])
+	}
+
+	c := converter(t, func(o *mdword.Options) { o.Strict = true })
+	if _, err := c.ParseMarkdown(strings.NewReader(markdown)); err == nil {
+		t.Error("duplicate keys parsed without error under -strict")
+	}
+}
+
+func TestParseMarkdownNoKeys(t *testing.T) {
+	markdown := "## Wrong Level\n\ntext\n"
+	_, err := converter(t, nil).ParseMarkdown(strings.NewReader(markdown))
+	if err == nil || !strings.Contains(err.Error(), "heading levels") {
+		t.Errorf("markdown without keys parsed with error %v", err)
+	}
+	if got := parse(t, markdown, func(o *mdword.Options) { o.AllowEmpty = true }); len(got) != 0 {
+		t.Errorf("got %q, want no keys", got)
+	}
+}
+
+// TestParseMarkdownGolden parses the markdown files of testdata/parse and compares the data
+// with the .json file of the same name. Run with -update to rewrite them.
+func TestParseMarkdownGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "parse", "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no markdown files in testdata/parse")
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := parse(t, string(content), nil)
+			golden := strings.TrimSuffix(file, ".md") + ".json"
+			if *update {
+				if err := mdword.WriteDataJSON(golden, got); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := mdword.LoadDataJSON(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got  %q\nwant %q", got, want)
+			}
+		})
+	}
+}
+
+func TestDefaultOutput(t *testing.T) {
+	tests := []struct {
+		input, dir, want string
+	}{
+		{"spec.md", "", "spec.docx"},
+		{filepath.Join("docs", "spec.md"), "", filepath.Join("docs", "spec.docx")},
+		{"README", "", "README.docx"},
+		{".spec", "", ".spec.docx"},
+		{".spec.md", "", ".spec.docx"},
+		{"spec.", "", "spec.docx"},
+		{filepath.Join("v1.2", "notes"), "", filepath.Join("v1.2", "notes.docx")},
+		{filepath.Join("docs", "spec.md"), "out", filepath.Join("out", "spec.docx")},
+		{filepath.Join("Zoë", "Ünïcode spec.md"), "", filepath.Join("Zoë", "Ünïcode spec.docx")},
+		{filepath.Join("日本", "仕様.v2.md"), "", filepath.Join("日本", "仕様.v2.docx")},
+		{filepath.Join("docs", "Café.md"), "Ausgabe Ä", filepath.Join("Ausgabe Ä", "Café.docx")},
+		// a Windows path is only split at its backslashes on Windows, and its name kept either way
+		{`C:\Users\Zoë\spec.md`, "", `C:\Users\Zoë\spec.docx`},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, struct{ input, dir, want string }{`C:\v1.2\notes`, `D:\out`, `D:\out\notes.docx`})
+	}
+	for _, tt := range tests {
+		if got := defaultOutput(tt.input, tt.dir, ".docx"); got != tt.want {
+			t.Errorf("defaultOutput(%q, %q) = %q, want %q", tt.input, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestCheckNotInput(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "spec.md")
+	if err := os.WriteFile(input, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkNotInput(filepath.Join(filepath.Dir(input), ".", "spec.md"), "", input); err == nil {
+		t.Error("output equal to the input accepted")
+	}
+	if err := checkNotInput(withExt(input, ".docx"), input); err != nil {
+		t.Errorf("distinct output rejected: %v", err)
+	}
+}
+
+func TestEscapedBraces(t *testing.T) {
+	markdown := "### Example\n\n" +
+		"```\n\\{\\{#if draft\\}\\}{{name}}\\{\\{/if\\}\\}\n```\n" +
+		"\\{\\{table: data.csv\\}\\} and {{plain}}\n\n" +
+		"### Inline\n\nUse \\{\\{name\\}\\} here\n"
+	outputFile := convert(t, markdown, mdword.Placeholder("example")+mdword.Placeholder("inline"), nil)
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"{{#if draft}}{{name}}{{/if}}", "{{table: data.csv}} and {{plain}}", "Use {{name}} here"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output text %q does not contain %q", text, want)
+		}
+	}
+	if strings.Contains(text, `\`) {
+		t.Errorf("output text %q keeps escapes", text)
+	}
+}
+
+func TestParseMarkdownFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.md")
+	second := filepath.Join(dir, "second.md")
+	if err := os.WriteFile(first, []byte("## A\n\n### B\n\none\n\n### C\n\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("### A B\n\ntwo\n\n### D\n\nd\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := converter(t, nil).ParseMarkdownFiles([]string{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a-b": "two", "a-c": "c", "d": "d"}
+	if !reflect.DeepEqual(doc.Data, want) {
+		t.Errorf("got  %q\nwant %q", doc.Data, want)
+	}
+	if doc.Source != first {
+		t.Errorf("source %q, want %q", doc.Source, first)
+	}
+
+	var files fileListFlags
+	for _, value := range []string{"a.md, b.md", "c.md"} {
+		if err := files.Set(value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := (fileListFlags{"a.md", "b.md", "c.md"}); !reflect.DeepEqual(files, want) {
+		t.Errorf("-markdown files %q, want %q", files, want)
+	}
+}
+
+func TestSmartyPants(t *testing.T) {
+	markdown := "### Prose\n\n\"Quoted\" and 'single' -- don't stop... 1990---2000 \"*emphasis*\" `\"code\" -- kept`\n\n" +
+		"### Plain\n\nHe said \"yes\" -- it's done\n"
+	outputFile := convert(t, markdown, mdword.Placeholder("prose")+"|"+mdword.Placeholder("plain"), func(o *mdword.Options) { o.SmartyPants = true })
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"“Quoted” and ‘single’ – don’t stop… 1990—2000 “emphasis”", `"code" -- kept`, "He said “yes” – it’s done"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output text %q does not contain %q", text, want)
+		}
+	}
+}
+
+func TestBackslashEscapes(t *testing.T) {
+	markdown := "### Path\n\nInstall to C:\\Program Files\\app_data and C:\\\\share\n\n" +
+		"### Escaped\n\nA \\*literal\\* asterisk, 5 \\* 3, \\_under\\_ and \\# not a heading with *italic*\n\n" +
+		"### Code\n\nRun `dir C:\\*.md` now\n"
+	outputFile := convert(t, markdown, mdword.Placeholder("path")+"|"+mdword.Placeholder("escaped")+"|"+mdword.Placeholder("code"), nil)
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`C:\Program Files\app_data and C:\share`,
+		"A *literal* asterisk, 5 * 3, _under_ and # not a heading with italic",
+		`dir C:\*.md`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("output text %q does not contain %q", text, want)
+		}
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	markdown := "# Title\n\n## Overview\n\n### Goals ###\n\ng\n\n#### Stretch **goals**\n\ns\n\n## Details\n\n### C#\n\nc\n"
+	outputFile := convert(t, markdown, mdword.Placeholder("toc"), func(o *mdword.Options) { o.TOC = true })
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "OverviewGoalsStretch goalsDetailsC#"
+	if !strings.Contains(text, want) {
+		t.Errorf("output text %q does not contain %q", text, want)
+	}
+	if strings.Contains(text, "Title") {
+		t.Errorf("output text %q lists the title heading", text)
+	}
+}
+
+func TestPrintPlaceholders(t *testing.T) {
+	templateFile := filepath.Join(t.TempDir(), "template.docx")
+	text := mdword.Placeholder("title") + " by " + mdword.Placeholder("author") + ", " + mdword.Placeholder("title")
+	if err := writeSelfTestTemplate(templateFile, text); err != nil {
+		t.Fatal(err)
+	}
+	placeholders, err := converter(t, nil).TemplatePlaceholders(templateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	printPlaceholders(&b, placeholders)
+	if got, want := b.String(), "author\ntitle\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestUnbalancedAsterisks(t *testing.T) {
+	values := []string{
+		"5 * 3 = 15",
+		"2*3 = 6 and 10 ** 2 = 100",
+		"See the note*",
+		"Rated ***",
+		"**never closed, then *italic*",
+	}
+	var markdown, text string
+	for i, value := range values {
+		key := fmt.Sprintf("v%d", i)
+		markdown += "### " + key + "\n\n" + value + "\n\n"
+		text += mdword.Placeholder(key) + "|"
+	}
+	outputFile := convert(t, markdown, text, nil)
+	got, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// emphasis drops its delimiters, so values whose asterisks all survive were not styled
+	want := "5 * 3 = 15|2*3 = 6 and 10 ** 2 = 100|See the note*|Rated ***|**never closed, then italic|"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestUnbalancedEmphasisModes(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "report.md"), []byte("### Body\n\nThis is *important and more\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "t.docx"), mdword.Placeholder("body")); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		mode, text string
+		italic     bool
+	}{
+		{"literal", "This is *important and more", false},
+		{"autoclose", "This is important and more", true},
+		{"warn", "This is *important and more", false},
+	} {
+		outputFile := filepath.Join(dir, test.mode+".docx")
+		_, stderr, code := runCommand(t, dir, nil, "-unbalanced-emphasis", test.mode, "-markdown", "report.md", "-template", "t.docx", "-output", outputFile)
+		if code != 0 {
+			t.Fatalf("%s: exit status %d: %s", test.mode, code, stderr)
+		}
+		text, err := documentText(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != test.text {
+			t.Errorf("%s: got %q, want %q", test.mode, text, test.text)
+		}
+		italic := strings.Contains(documentXML(t, outputFile), `<w:i/></w:rPr><w:t xml:space="preserve">important and more</w:t>`)
+		if italic != test.italic {
+			t.Errorf("%s: italic %v, want %v", test.mode, italic, test.italic)
+		}
+		if warned := strings.Contains(stderr, "emphasis marker * is never closed"); warned != (test.mode == "warn") {
+			t.Errorf("%s: stderr %q", test.mode, stderr)
+		}
+	}
+	if _, stderr, code := runCommand(t, dir, nil, "-unbalanced-emphasis", "close", "-markdown", "report.md", "-template", "t.docx"); code == 0 || !strings.Contains(stderr, "-unbalanced-emphasis must be literal, autoclose or warn") {
+		t.Errorf("invalid mode: exit status %d: %s", code, stderr)
+	}
+}
+
+func TestInlineTags(t *testing.T) {
+	markdown := "### Tags\n\none<br>two<BR/>three <b>bold</b> <EM>em</EM> H<sub>2</sub>O </i><foo>kept</foo> `<b>code</b>`\n"
+	outputFile := convert(t, markdown, mdword.Placeholder("tags"), nil)
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "onetwothree bold em H2O <foo>kept</foo> <b>code</b>"; text != want {
+		t.Errorf("got  %q\nwant %q", text, want)
+	}
+	document := documentXML(t, outputFile)
+	for _, want := range []string{
+		`<w:br/><w:t xml:space="preserve">two</w:t>`,
+		`<w:br/><w:t xml:space="preserve">three </w:t>`,
+		`<w:b/></w:rPr><w:t xml:space="preserve">bold</w:t>`,
+		`<w:i/></w:rPr><w:t xml:space="preserve">em</w:t>`,
+		`<w:vertAlign w:val="subscript"/></w:rPr><w:t xml:space="preserve">2</w:t>`,
+	} {
+		if !strings.Contains(document, want) {
+			t.Errorf("document does not contain %s", want)
+		}
+	}
+}
+
+// documentXML returns the main document part of a docx file.
+func documentXML(t *testing.T, path string) string {
+	t.Helper()
+	return docxParts(t, path)["word/document.xml"]
+}
+
+// docxParts returns the parts of a docx file by name.
+func docxParts(t *testing.T, path string) map[string]string {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	parts := make(map[string]string, len(zr.File))
+	for _, zf := range zr.File {
+		f, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[zf.Name] = string(content)
+	}
+	return parts
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	want := map[string][]string{
+		"template": {"report.docx"},
+		"bullet":   {"–"},
+		"strict":   {"true"},
+		"columns":  {"3"},
+		"set":      {"a=1", "b=x # y"},
+	}
+	files := map[string]string{
+		"ci.toml": "# CI defaults\ntemplate = \"report.docx\" # the template\nbullet = '–'\nstrict = true\ncolumns = 3\nset = [\"a=1\", 'b=x # y']\n",
+		"ci.json": `{"template": "report.docx", "bullet": "–", "strict": true, "columns": 3, "set": ["a=1", "b=x # y"]}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := loadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q\nwant %q", name, got, want)
+		}
+	}
+
+	path := filepath.Join(dir, "numbers.json")
+	if err := os.WriteFile(path, []byte(`{"jobs": 1000000, "columns": [2, 0.000001, 12345678901234567890]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string][]string{"jobs": {"1000000"}, "columns": {"2", "0.000001", "12345678901234567890"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("numbers.json: got %q\nwant %q", got, want)
+	}
+	if err := os.WriteFile(path, []byte(`{"jobs": 1} {"jobs": 2}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig(path); err == nil {
+		t.Error("config with trailing data loaded without error")
+	}
+
+	for _, content := range []string{"[flags]\n", "template = report.docx\n", "template = \"report.docx\n", "set = [\"a\" \"b\"]\n", "template\n"} {
+		path := filepath.Join(dir, "bad.toml")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("config %q loaded without error", content)
+		}
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{"", ""},
+		{"-", "-"},
+		{"./docs//spec.md", filepath.Join("docs", "spec.md")},
+		{"docs/../Ünïcode spec.md", "Ünïcode spec.md"},
+		{"file:///tmp/My%20Docs/sp%C3%A9c.md", filepath.FromSlash("/tmp/My Docs/spéc.md")},
+		{"100%25.md", "100%25.md"},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, struct{ path, want string }{"file:///C:/Users/Zo%C3%AB/spec.md", `C:\Users\Zoë\spec.md`})
+	}
+	for _, tt := range tests {
+		if got := localPath(tt.path); got != tt.want {
+			t.Errorf("localPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPercentEncodedImagePath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Entwürfe")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewGray(image.Rect(0, 0, 4, 4))
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Übersicht diagram.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	markdownFile := filepath.Join(dir, "spec.md")
+	markdown := "### Figure\n\n![Overview](%C3%9Cbersicht%20diagram.png)\n"
+	if err := os.WriteFile(markdownFile, []byte(markdown), 0644); err != nil {
+		t.Fatal(err)
+	}
+	templateFile := filepath.Join(dir, "template.docx")
+	outputFile := filepath.Join(dir, "output.docx")
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("figure")); err != nil {
+		t.Fatal(err)
+	}
+	if err := converter(t, nil).ConvertFile(markdownFile, templateFile, outputFile, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	document := documentXML(t, outputFile)
+	if !strings.Contains(document, "<w:drawing>") || strings.Contains(document, "missing image") {
+		t.Errorf("percent-encoded image path not embedded: %s", document)
+	}
+}
+
+func TestNewConverterErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*mdword.Options)
+		want      string
+	}{
+		{"key style", func(o *mdword.Options) { o.KeyStyle = "number" }, "-key-style"},
+		{"empty bullet", func(o *mdword.Options) { o.Bullet = "" }, "-bullet"},
+		{"long delimiter", func(o *mdword.Options) { o.OpenDelim = "{{" }, "-open-delim"},
+		{"same delimiters", func(o *mdword.Options) { o.OpenDelim, o.CloseDelim = "|", "|" }, "must differ"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := mdword.DefaultOptions()
+			tt.configure(&opts)
+			if _, err := mdword.NewConverter(opts); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one mentioning %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertersIndependent(t *testing.T) {
+	markdown := "### Items\n\n- one\n- two\n"
+	dashes := converter(t, func(o *mdword.Options) { o.Bullet = "-"; o.OpenDelim, o.CloseDelim = "[", "]" })
+	bullets := converter(t, nil)
+
+	dir := t.TempDir()
+	for _, c := range []*mdword.Converter{dashes, bullets, dashes} {
+		doc, err := c.ParseMarkdown(strings.NewReader(markdown))
+		if err != nil {
+			t.Fatal(err)
+		}
+		templateFile := filepath.Join(dir, "template.docx")
+		outputFile := filepath.Join(dir, "output.docx")
+		if err := writeSelfTestTemplate(templateFile, c.Placeholder("items")); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.RenderFile(doc, templateFile, outputFile); err != nil {
+			t.Fatalf("RenderFile with %s: %v", c.Placeholder("items"), err)
+		}
+		text, err := documentText(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bullet := "•"
+		if c == dashes {
+			bullet = "-"
+		}
+		if want := bullet + " one"; !strings.Contains(text, want) {
+			t.Errorf("output text of %s %q does not contain %q", c.Placeholder("items"), text, want)
+		}
+	}
+}
+
+func TestDocumentGlossary(t *testing.T) {
+	markdown := "### Intro\n\nText.\n\nApple\n: A fruit.\n"
+	c := converter(t, nil)
+	doc, err := c.ParseMarkdown(strings.NewReader(markdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a document holds its own definitions, a second parse does not add to or replace them
+	if _, err := c.ParseMarkdown(strings.NewReader("### Other\n\nBanana\n: Yellow.\n")); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	templateFile := filepath.Join(dir, "template.docx")
+	outputFile := filepath.Join(dir, "output.docx")
+	if err := writeSelfTestTemplate(templateFile, c.Placeholder("glossary")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RenderFile(doc, templateFile, outputFile); err != nil {
+		t.Fatal(err)
+	}
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "AppleA fruit.") || strings.Contains(text, "Banana") {
+		t.Errorf("glossary text %q, want only the definitions of the rendered document", text)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	data, err := mdword.ParseMarkdown(strings.NewReader("### Greeting\n\nHello\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	templateFile := filepath.Join(t.TempDir(), "template.docx")
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("greeting")+" world"); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := mdword.RenderTemplate(templateFile, data, &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() == 0 {
+		t.Error("RenderTemplate wrote nothing")
+	}
+}
+
+func TestConvertDirJobs(t *testing.T) {
+	saved := out
+	out = io.Discard
+	defer func() { out = saved }()
+
+	dir := t.TempDir()
+	inputDir := filepath.Join(dir, "in")
+	if err := os.Mkdir(inputDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 8; i++ {
+		markdown := fmt.Sprintf("### Body\n\nDocument %d with **bold** and `code`.\n\n- one\n- two %d\n\n```go\nfmt.Println(%d)\n```\n\nTerm %d\n: Defined.\n", i, i, i, i)
+		if err := os.WriteFile(filepath.Join(inputDir, fmt.Sprintf("doc%d.md", i)), []byte(markdown), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	templateFile := filepath.Join(dir, "template.docx")
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("body")+mdword.Placeholder("glossary")); err != nil {
+		t.Fatal(err)
+	}
+
+	c := converter(t, func(o *mdword.Options) { o.Highlight = true })
+	for _, jobs := range []int{1, 4} {
+		outputDir := filepath.Join(dir, fmt.Sprint(jobs))
+		if err := os.Mkdir(outputDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if failed, err := convertDir(c, templateFile, inputDir, outputDir, jobs, nil, nil); err != nil || failed > 0 {
+			t.Fatalf("-jobs %d: %d failed, %v", jobs, failed, err)
+		}
+	}
+	for i := 1; i <= 8; i++ {
+		name := fmt.Sprintf("doc%d.docx", i)
+		serial := documentXML(t, filepath.Join(dir, "1", name))
+		parallel := documentXML(t, filepath.Join(dir, "4", name))
+		if serial != parallel {
+			t.Errorf("%s differs between -jobs 1 and -jobs 4:\n%s\n%s", name, serial, parallel)
+		}
+		if !strings.Contains(serial, fmt.Sprintf("Document %d ", i)) {
+			t.Errorf("%s does not hold its own document:\n%s", name, serial)
+		}
+	}
+}
+
+func TestRedline(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "old.md"), []byte("### Body\n\nThe quick brown fox\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.md"), []byte("### Body\n\nThe quick fox jumps\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "t.docx"), mdword.Placeholder("body")); err != nil {
+		t.Fatal(err)
+	}
+	// flags may follow the old and new files
+	if _, stderr, code := runCommand(t, dir, nil, "-redline", "old.md", "new.md", "-template", "t.docx"); code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	xml := documentXML(t, filepath.Join(dir, "new.docx"))
+	for _, want := range []string{
+		`<w:delText xml:space="preserve">brown </w:delText>`,
+		`<w:t xml:space="preserve"> jumps</w:t></w:r></w:ins>`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+	if !strings.Contains(xml, "<w:del ") || !strings.Contains(xml, "<w:ins ") {
+		t.Errorf("document XML lacks a deletion or insertion revision:\n%s", xml)
+	}
+}
+
+func TestKeepTrailingBlank(t *testing.T) {
+	markdown := "### Body\n\nText\n\n### Next\n\nn\n"
+	paragraphs := func(configure func(*mdword.Options)) int {
+		xml := documentXML(t, convert(t, markdown, mdword.Placeholder("body"), configure))
+		return strings.Count(xml, "<w:p>") + strings.Count(xml, "<w:p ")
+	}
+	trimmed := paragraphs(nil)
+	kept := paragraphs(func(o *mdword.Options) { o.KeepTrailingBlank = true })
+	if kept != trimmed+1 {
+		t.Errorf("%d paragraphs with -keep-trailing-blank, want one more than the %d without", kept, trimmed)
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	if err := runSelfTest(converter(t, nil)); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, code := runCommand(t, t.TempDir(), nil, "-selftest")
+	if code != 0 || !strings.Contains(stdout, "Self test passed") {
+		t.Errorf("-selftest exited %d with %q, %q", code, stdout, stderr)
+	}
+}
+
+func TestBulletEncoding(t *testing.T) {
+	xml := documentXML(t, convert(t, "### List\n\n- first\n", mdword.Placeholder("list"), nil))
+	if !strings.Contains(xml, "• first") {
+		t.Errorf("document XML does not contain the bullet:\n%s", xml)
+	}
+	// the UTF-8 bytes of • read as Windows-1252
+	if strings.Contains(xml, "â€¢") {
+		t.Errorf("document XML contains a double-encoded bullet:\n%s", xml)
+	}
+}
+
+func TestBoldListLeadIn(t *testing.T) {
+	xml := documentXML(t, convert(t, "### List\n\n- **bold** item\n", mdword.Placeholder("list"), nil))
+	want := `<w:r><w:t xml:space="preserve">• </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t xml:space="preserve">bold</w:t></w:r><w:r><w:t xml:space="preserve"> item</w:t></w:r>`
+	if !strings.Contains(xml, want) {
+		t.Errorf("document XML does not contain a plain bullet and a bold lead-in:\n%s", xml)
+	}
+}
+
+func TestOrdinalKeys(t *testing.T) {
+	ordinal := func(o *mdword.Options) { o.KeyStyle = "ordinal" }
+	before := parse(t, "## Intro\n\n### Goals\n\ng\n\n### Scope\n\ns\n\n## Plan\n\nOwner\n: Kim\n\n### Steps\n\nx\n", ordinal)
+	after := parse(t, "## Introduction\n\n### Aims\n\ng\n\n### Boundaries\n\ns\n\n## Schedule\n\nOwner\n: Kim\n\n### Milestones\n\nx\n", ordinal)
+	want := map[string]string{"section-1": "g", "section-2": "s", "section-3": "x", "section-2-owner": "Kim"}
+	if !reflect.DeepEqual(before, want) {
+		t.Errorf("got  %q\nwant %q", before, want)
+	}
+	if !reflect.DeepEqual(after, before) {
+		t.Errorf("renamed headings give %q, want %q", after, before)
+	}
+}
+
+func TestParagraphStyles(t *testing.T) {
+	markdown := "### Body\n\nSome text.\n\n- item\n\n```\ncode\n```\n"
+	xml := documentXML(t, convert(t, markdown, mdword.Placeholder("body"), func(o *mdword.Options) {
+		o.ParagraphStyle, o.ListStyle, o.CodeStyle = "BodyText", "ListBullet", "Code"
+	}))
+	for _, want := range []string{
+		`<w:pStyle w:val="BodyText"/></w:pPr><w:r><w:t xml:space="preserve">Some text.</w:t>`,
+		`<w:pStyle w:val="ListBullet"/>`,
+		`<w:pStyle w:val="Code"/>`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+
+	xml = documentXML(t, convert(t, markdown, mdword.Placeholder("body"), nil))
+	if strings.Contains(xml, "<w:pStyle") {
+		t.Errorf("document XML has a paragraph style without the flags:\n%s", xml)
+	}
+}
+
+func TestDataPrecedence(t *testing.T) {
+	defaultsFile := filepath.Join(t.TempDir(), "defaults.json")
+	content := `{"count": 1000000, "ratio": 0.000001, "big": 12345678901234567890, "draft": true, "none": null, "a": "default", "b": "default", "c": "default"}`
+	if err := os.WriteFile(defaultsFile, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := mdword.LoadDataJSON(defaultsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	markdown := parse(t, "### B\n\nmarkdown\n\n### C\n\nmarkdown\n", nil)
+	sets := map[string]string{"c": "set"}
+
+	got := mdword.MergeData(defaults, markdown, sets)
+	want := map[string]string{
+		"count": "1000000", "ratio": "0.000001", "big": "12345678901234567890", "draft": "true", "none": "",
+		"a": "default", "b": "markdown", "c": "set",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	if err := os.WriteFile(defaultsFile, []byte(`{"a": {"nested": 1}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mdword.LoadDataJSON(defaultsFile); err == nil {
+		t.Error("nested object loaded without error")
+	}
+}
+
+func TestSchema(t *testing.T) {
+	dir := t.TempDir()
+	schemaFile := filepath.Join(dir, "schema.json")
+	schema := `{
+		"required": ["title", "version"],
+		"properties": {
+			"version": {"pattern": "^\\d+\\.\\d+$"},
+			"summary": {"minLength": 3, "maxLength": 10}
+		}
+	}`
+	if err := os.WriteFile(schemaFile, []byte(schema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := mdword.LoadSchema(schemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passing := parse(t, "### Title\n\nReport\n\n### Version\n\n1.2\n\n### Summary\n\nShort\n", nil)
+	if violations := s.Validate(passing); len(violations) != 0 {
+		t.Errorf("passing document has violations %q", violations)
+	}
+
+	failing := parse(t, "### Version\n\nv1\n\n### Summary\n\nFar too long a summary\n", nil)
+	want := []string{
+		`missing required key "title"`,
+		`value of "summary" is 22 characters long, more than the maximum of 10`,
+		`value of "version" does not match pattern "^\\d+\\.\\d+$"`,
+	}
+	if violations := s.Validate(failing); !reflect.DeepEqual(violations, want) {
+		t.Errorf("got  %q\nwant %q", violations, want)
+	}
+
+	// -strict turns the violations into a failure
+	if err := os.WriteFile(filepath.Join(dir, "failing.md"), []byte("### Version\n\nv1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-markdown", "failing.md", "-schema", "schema.json", "-emit-data", "data.json"}
+	if _, stderr, code := runCommand(t, dir, nil, args...); code != 0 || !strings.Contains(stderr, `missing required key "title"`) {
+		t.Errorf("exit status %d without -strict, want 0 and a warning: %s", code, stderr)
+	}
+	if _, stderr, code := runCommand(t, dir, nil, append(args, "-strict")...); code != 1 {
+		t.Errorf("exit status %d with -strict, want 1: %s", code, stderr)
+	}
+}
+
+func TestCheckTemplateKeepsOrder(t *testing.T) {
+	placeholders := []string{"title", "author", "title", "summary:80"}
+	data := map[string]string{"title": "t", "author": "a", "summary": "s"}
+	if problems := converter(t, nil).CheckTemplate(placeholders, data); len(problems) != 0 {
+		t.Errorf("matching template has problems %q", problems)
+	}
+	if want := []string{"title", "author", "title", "summary:80"}; !reflect.DeepEqual(placeholders, want) {
+		t.Errorf("CheckTemplate reordered the placeholders to %q", placeholders)
+	}
+}
+
+func TestLoadMergeRows(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		file, content string
+		want          []map[string]string
+	}{
+		{"rows.csv", "name , city\nAda,London\n\"Lin, Yu\",Taipei\n", []map[string]string{{"name": "Ada", "city": "London"}, {"name": "Lin, Yu", "city": "Taipei"}}},
+		{"rows.tsv", "name\tcity\nAda\tLondon\n", []map[string]string{{"name": "Ada", "city": "London"}}},
+		{"rows.json", `[{"name": "Ada", "amount": 1000000, "rate": 0.5, "paid": false, "note": null}]`, []map[string]string{{"name": "Ada", "amount": "1000000", "rate": "0.5", "paid": "false"}}},
+		{"empty.csv", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := mdword.LoadMergeRows(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+
+	path := filepath.Join(dir, "nested.json")
+	if err := os.WriteFile(path, []byte(`[{"name": ["a", "b"]}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mdword.LoadMergeRows(path); err == nil {
+		t.Error("array value loaded without error")
+	}
+}
+
+func TestMergeOutputPath(t *testing.T) {
+	row := map[string]string{"name": "Ada/Lovelace"}
+	if got, want := mdword.MergeOutputPath("out/{n}-{name}-{missing}.docx", 2, row), "out/2-Ada-Lovelace-{missing}.docx"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMergeData(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "rows.csv"), []byte("name,amount\nAda,10\nLin,20\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "t.docx"), "Dear "+mdword.Placeholder("name")+", you owe "+mdword.Placeholder("amount")); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-merge-data", "rows.csv", "-template", "t.docx", "-output-pattern", "letter-{name}.docx"}
+	if _, stderr, code := runCommand(t, dir, nil, args...); code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	for name, want := range map[string]string{"letter-Ada.docx": "Dear Ada, you owe 10", "letter-Lin.docx": "Dear Lin, you owe 20"} {
+		text, err := documentText(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != want {
+			t.Errorf("%s holds %q, want %q", name, text, want)
+		}
+	}
+}
+
+func TestDocumentLanguage(t *testing.T) {
+	tests := []struct {
+		name, markdown string
+		configure      func(*mdword.Options)
+		want           string
+	}{
+		{"flag", "### Body\n\nHallo\n", func(o *mdword.Options) { o.DocLang = "de-DE" }, "de-DE"},
+		{"front matter", "---\nlang: fr-FR\n---\n### Body\n\nBonjour\n", nil, "fr-FR"},
+		{"flag overrides front matter", "---\nlang: fr-FR\n---\n### Body\n\nHallo\n", func(o *mdword.Options) { o.DocLang = "de-DE" }, "de-DE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xml := documentXML(t, convert(t, tt.markdown, mdword.Placeholder("body"), tt.configure))
+			if want := `<w:lang w:val="` + tt.want + `"/>`; !strings.Contains(xml, want) {
+				t.Errorf("document XML does not contain %s:\n%s", want, xml)
+			}
+		})
+	}
+
+	xml := documentXML(t, convert(t, "### Body\n\nHello\n", mdword.Placeholder("body"), nil))
+	if strings.Contains(xml, "<w:lang") {
+		t.Errorf("document XML sets a language without -doc-lang or lang key:\n%s", xml)
+	}
+}
+
+func TestStampFooter(t *testing.T) {
+	dir := t.TempDir()
+	markdownFile := filepath.Join(dir, "report.md")
+	templateFile := filepath.Join(dir, "template.docx")
+	outputFile := filepath.Join(dir, "report.docx")
+	if err := os.WriteFile(markdownFile, []byte("### Body\n\ntext\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("body")); err != nil {
+		t.Fatal(err)
+	}
+	c := converter(t, func(o *mdword.Options) { o.FooterFormat = "From {source} on {date}, page {page}" })
+	if err := c.ConvertFile(markdownFile, templateFile, outputFile, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := docxParts(t, outputFile)
+	footer, ok := parts["word/footer1.xml"]
+	if !ok {
+		t.Fatalf("no footer part in %q", parts)
+	}
+	date := time.Now().Format("2006-01-02")
+	for _, want := range []string{
+		"From report.md on " + date + ", page ",
+		`<w:fldSimple w:instr=" PAGE ">`,
+	} {
+		if !strings.Contains(footer, want) {
+			t.Errorf("footer does not contain %s:\n%s", want, footer)
+		}
+	}
+	if !strings.Contains(parts["word/document.xml"], `<w:footerReference w:type="default"`) {
+		t.Errorf("document does not reference the footer:\n%s", parts["word/document.xml"])
+	}
+	if !strings.Contains(parts["[Content_Types].xml"], "/word/footer1.xml") {
+		t.Errorf("footer has no content type:\n%s", parts["[Content_Types].xml"])
+	}
+}
+
+func TestTruncatedPlaceholder(t *testing.T) {
+	markdown := "### Summary\n\na **bold statement** follows\n"
+	outputFile := convert(t, markdown, mdword.Placeholder("summary:10")+"|"+mdword.Placeholder("summary"), nil)
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a bold…|a bold statement follows"; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+
+	// rendering leaves the caller's data alone and counts only its keys
+	dir := t.TempDir()
+	templateFile := filepath.Join(dir, "template.docx")
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("summary:10")); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]string{"summary": "a bold statement follows"}
+	want := map[string]string{"summary": "a bold statement follows"}
+	c := converter(t, nil)
+	for i := 0; i < 2; i++ {
+		if err := mdword.RenderTemplate(templateFile, data, io.Discard); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Render(&mdword.Document{Data: data}, templateFile, io.Discard); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(data, want) {
+			t.Fatalf("render %d changed the data to %q", i+1, data)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte(markdown), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code := runCommand(t, dir, nil, "-markdown", "doc.md", "-template", "template.docx", "-output", "out.docx", "-stats")
+	if code != 0 || !strings.Contains(stderr, "Keys: 1\n") {
+		t.Errorf("-stats: exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestInteractiveCheckboxes(t *testing.T) {
+	markdown := "### Tasks\n\n- [x] done\n- [ ] open\n- plain\n"
+	xml := documentXML(t, convert(t, markdown, mdword.Placeholder("tasks"), func(o *mdword.Options) { o.InteractiveCheckboxes = true }))
+	if n := strings.Count(xml, "<w14:checkbox>"); n != 2 {
+		t.Errorf("document XML has %d checkbox controls, want 2:\n%s", n, xml)
+	}
+	checked := strings.Index(xml, `<w14:checked w14:val="1"/>`)
+	unchecked := strings.Index(xml, `<w14:checked w14:val="0"/>`)
+	if checked < 0 || unchecked < 0 || checked > unchecked {
+		t.Errorf("document XML does not hold a checked then an unchecked control:\n%s", xml)
+	}
+	for _, want := range []string{`xmlns:w14="http://schemas.microsoft.com/office/word/2010/wordml"`, " done", " open", "• plain"} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+	if strings.Contains(xml, "[x]") || strings.Contains(xml, "[ ]") {
+		t.Errorf("document XML keeps the task markers:\n%s", xml)
+	}
+
+	xml = documentXML(t, convert(t, markdown, mdword.Placeholder("tasks"), nil))
+	if strings.Contains(xml, "<w14:checkbox>") || !strings.Contains(xml, "[x] done") {
+		t.Errorf("task list not left as text without -interactive-checkboxes:\n%s", xml)
+	}
+}
+
+func TestPrintPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "report.md"), []byte("### Body\n\ntext\n\n### Unused\n\nu\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "t.docx"), mdword.Placeholder("body")); err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, code := runCommand(t, dir, nil, "-print-path", "-v", "-stats", "-markdown", "report.md", "-template", "t.docx", "-output", "out.docx")
+	if code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	if stdout != "out.docx\n" {
+		t.Errorf("stdout %q, want only the output path", stdout)
+	}
+	if !strings.Contains(stderr, "Warning: keys matching no placeholder") {
+		t.Errorf("stderr %q lacks the warning", stderr)
+	}
+}
+
+func TestCheckTemplateCase(t *testing.T) {
+	placeholders := []string{"Version", "title", "Summary:80", "Version"}
+	data := map[string]string{"version": "1", "title": "t", "summary": "s"}
+	got := converter(t, nil).CheckTemplate(placeholders, data)
+	want := []string{
+		`placeholder {Summary:80} differs only by case from key "summary" and will not be filled, write it as {summary:80}`,
+		`placeholder {Version} differs only by case from key "version" and will not be filled, write it as {version}`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte("### Version\n\n1.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "t.docx"), "v"+mdword.Placeholder("Version")); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code := runCommand(t, dir, nil, "-check", "-markdown", "doc.md", "-template", "t.docx")
+	if code != 1 || !strings.Contains(stderr, "write it as {version}") {
+		t.Errorf("-check exited %d with %q, want 1 and the case mismatch", code, stderr)
+	}
+}
+
+func TestEmitDataRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	markdown := "---\nversion: 2\n---\n### Body\n\nSome **bold** text\n\n- one\n- two\n\n### Quote\n\n\"Hi\" & <bye>\n"
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte(markdown), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "defaults.json"), []byte(`{"author": "Ada", "count": 1000000}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	text := mdword.Placeholder("body") + mdword.Placeholder("quote") + mdword.Placeholder("version") + mdword.Placeholder("author") + mdword.Placeholder("count") + mdword.Placeholder("extra")
+	if err := writeSelfTestTemplate(filepath.Join(dir, "t.docx"), text); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runCommand(t, dir, nil, "-markdown", "doc.md", "-defaults", "defaults.json", "-set", "extra=x=y", "-emit-data", "-")
+	if code != 0 {
+		t.Fatalf("-emit-data exited %d: %s", code, stderr)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data.json"), []byte(stdout), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	emitted, err := mdword.LoadDataJSON(filepath.Join(dir, "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := parse(t, markdown, nil)
+	want["author"], want["count"], want["extra"] = "Ada", "1000000", "x=y"
+	if !reflect.DeepEqual(emitted, want) {
+		t.Errorf("emitted %q\nwant    %q", emitted, want)
+	}
+
+	if _, stderr, code := runCommand(t, dir, nil, "-markdown", "doc.md", "-defaults", "defaults.json", "-set", "extra=x=y", "-template", "t.docx", "-output", "direct.docx"); code != 0 {
+		t.Fatalf("conversion exited %d: %s", code, stderr)
+	}
+	if _, stderr, code := runCommand(t, dir, nil, "-data-json", "data.json", "-template", "t.docx", "-output", "replayed.docx"); code != 0 {
+		t.Fatalf("conversion of the emitted data exited %d: %s", code, stderr)
+	}
+	if direct, replayed := documentXML(t, filepath.Join(dir, "direct.docx")), documentXML(t, filepath.Join(dir, "replayed.docx")); direct != replayed {
+		t.Errorf("document from emitted data differs:\n%s\n%s", direct, replayed)
+	}
+}
+
+// editPart rewrites the part name of the docx file at path with edit.
+func editPart(t *testing.T, path, name string, edit func(string) string) {
+	t.Helper()
+	parts := docxParts(t, path)
+	if _, ok := parts[name]; !ok {
+		t.Fatalf("%s has no part %s", path, name)
+	}
+	parts[name] = edit(parts[name])
+	writeParts(t, path, parts)
+}
+
+// writeParts writes the parts, keyed by name, as the docx file path.
+func writeParts(t *testing.T, path string, parts map[string]string) {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, content := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDocumentTitle(t *testing.T) {
+	const core = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">%s<dc:creator>Kim</dc:creator></cp:coreProperties>`
+	for _, templateTitle := range []string{"", "<dc:title>Template title</dc:title>"} {
+		dir := t.TempDir()
+		templateFile := filepath.Join(dir, "template.docx")
+		if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("body")); err != nil {
+			t.Fatal(err)
+		}
+		parts := docxParts(t, templateFile)
+		parts["docProps/core.xml"] = fmt.Sprintf(core, templateTitle)
+		writeParts(t, templateFile, parts)
+
+		c := converter(t, nil)
+		doc, err := c.ParseMarkdown(strings.NewReader("### Title\n\nQuarterly   Report & Outlook\n\n### Body\n\ntext\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputFile := filepath.Join(dir, "output.docx")
+		if err := c.RenderFile(doc, templateFile, outputFile); err != nil {
+			t.Fatal(err)
+		}
+		xml := docxParts(t, outputFile)["docProps/core.xml"]
+		if got := strings.Count(xml, "<dc:title>"); got != 1 {
+			t.Errorf("template title %q: core properties hold %d titles:\n%s", templateTitle, got, xml)
+		}
+		for _, want := range []string{"<dc:title>Quarterly Report &amp; Outlook</dc:title>", "<dc:creator>Kim</dc:creator>"} {
+			if !strings.Contains(xml, want) {
+				t.Errorf("template title %q: core properties do not contain %s:\n%s", templateTitle, want, xml)
+			}
+		}
+	}
+}
+
+func TestColumnBreak(t *testing.T) {
+	dir := t.TempDir()
+	templateFile := filepath.Join(dir, "template.docx")
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("brochure")); err != nil {
+		t.Fatal(err)
+	}
+	editPart(t, templateFile, "word/document.xml", func(xml string) string {
+		return strings.Replace(xml, "</w:body>", `<w:sectPr><w:pgSz w:w="12240" w:h="15840"/></w:sectPr></w:body>`, 1)
+	})
+	markdown := "### Brochure\n\nLeft column.\n\n<!-- column-break -->\n\nRight column.\n"
+
+	render := func(columns int) string {
+		c := converter(t, func(o *mdword.Options) { o.Columns = columns })
+		doc, err := c.ParseMarkdown(strings.NewReader(markdown))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputFile := filepath.Join(dir, fmt.Sprintf("columns%d.docx", columns))
+		if err := c.RenderFile(doc, templateFile, outputFile); err != nil {
+			t.Fatal(err)
+		}
+		return documentXML(t, outputFile)
+	}
+
+	xml := render(3)
+	for _, want := range []string{
+		`Left column.</w:t></w:r><w:r><w:br w:type="column"/></w:r>`,
+		`<w:type w:val="continuous"/><w:pgSz w:w="12240" w:h="15840"/><w:cols w:num="3" w:space="720"/></w:sectPr></w:pPr></w:p>`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+	if n := strings.Count(xml, "<w:sectPr>"); n != 3 {
+		t.Errorf("document XML has %d sections, want the value's two and the body's:\n%s", n, xml)
+	}
+
+	xml = render(1)
+	if !strings.Contains(xml, `<w:br w:type="column"/>`) || strings.Contains(xml, "<w:cols") {
+		t.Errorf("-columns 1 changed the section layout or dropped the break:\n%s", xml)
+	}
+}
+
+func TestRequirePlaceholders(t *testing.T) {
+	dir := t.TempDir()
+	contract := "# contract of the report template\n\ntitle\n{author}\n  summary  \n"
+	if err := os.WriteFile(filepath.Join(dir, "contract.txt"), []byte(contract), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := converter(t, nil)
+	required, err := c.LoadPlaceholderList(filepath.Join(dir, "contract.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"title", "author", "summary"}; !reflect.DeepEqual(required, want) {
+		t.Errorf("got contract %q, want %q", required, want)
+	}
+	if missing := mdword.MissingPlaceholders(required, []string{"summary", "title", "extra"}); !reflect.DeepEqual(missing, []string{"author"}) {
+		t.Errorf("got missing %q, want author", missing)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte("### Title\n\nt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "t.docx"), mdword.Placeholder("title")+mdword.Placeholder("summary")); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code := runCommand(t, dir, nil, "-require-placeholders", "contract.txt", "-markdown", "doc.md", "-template", "t.docx")
+	if code != 1 || !strings.Contains(stderr, "missing required placeholders: author") {
+		t.Errorf("exit status %d with %q, want 1 and the missing placeholder", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "doc.docx")); err == nil {
+		t.Error("document written for a template missing a required placeholder")
+	}
+}
+
+func TestGlossary(t *testing.T) {
+	markdown := "## Terms\n\ncherry\n: Red.\n\nBanana\n: Yellow\n  and long.\n\napple : A fruit.\n"
+	xml := documentXML(t, convert(t, markdown, mdword.Placeholder("glossary"), nil))
+	if n := strings.Count(xml, "<w:tr>"); n != 4 {
+		t.Errorf("glossary has %d rows, want a header and three terms:\n%s", n, xml)
+	}
+	text, err := documentText(convert(t, markdown, mdword.Placeholder("glossary"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "TermDefinitionappleA fruit.BananaYellowand long.cherryRed."; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+
+	// a glossary key of the data is used instead
+	text, err = documentText(convert(t, "### Glossary\n\nSee the appendix.\n\n## Terms\n\nTerm\n: t\n", mdword.Placeholder("glossary"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "See the appendix."; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	markdown := "---\nsteps: from front matter\n---\n## Intro\n\n### Steps\n\n- one\n- two\n\n\n### Other\n\no\n"
+	c := converter(t, func(o *mdword.Options) { o.ExplainKey = "intro-steps" })
+	doc, err := c.ParseMarkdown(strings.NewReader(markdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := map[string]string{"intro-steps": "default"}
+	sets := map[string]string{"intro-other": "set"}
+	data := mdword.MergeData(defaults, doc.Data, sets)
+
+	var b strings.Builder
+	doc.Explain(&b, "intro-steps", defaults, sets, data)
+	want := `Explaining "intro-steps"
+1. defaults: "default"
+2. line 6: heading "### Steps" gives the key
+3. collected lines: "\n- one\n- two\n\n\n"
+4. list markers become bullets: "\n• one\n• two\n\n\n"
+5. surrounding whitespace trimmed: "• one\n• two"
+6. markdown value: "• one\n• two"
+Final value: "• one\n• two"
+`
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	b.Reset()
+	doc.Explain(&b, "intro-other", defaults, sets, data)
+	if got := b.String(); !strings.Contains(got, `-set overrides the value: "set"`) || !strings.HasSuffix(got, "Final value: \"set\"\n") {
+		t.Errorf("explanation of an overridden key:\n%s", got)
+	}
+
+	b.Reset()
+	doc.Explain(&b, "missing", defaults, sets, data)
+	if got := b.String(); !strings.Contains(got, "No source produces this key. Known keys:\n  intro-other\n  intro-steps\n  steps\n") {
+		t.Errorf("explanation of a missing key:\n%s", got)
+	}
+}
+
+func TestSubscriptSuperscript(t *testing.T) {
+	markdown := "### Formula\n\nH~2~O and E = mc^2^, ~~struck~~ and 5~10 items\n"
+	outputFile := convert(t, markdown, mdword.Placeholder("formula"), nil)
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "H2O and E = mc2, struck and 5~10 items"; text != want {
+		t.Errorf("got  %q\nwant %q", text, want)
+	}
+	xml := documentXML(t, outputFile)
+	for _, want := range []string{
+		`<w:vertAlign w:val="subscript"/></w:rPr><w:t xml:space="preserve">2</w:t>`,
+		`<w:vertAlign w:val="superscript"/></w:rPr><w:t xml:space="preserve">2</w:t>`,
+		`<w:strike/></w:rPr><w:t xml:space="preserve">struck</w:t>`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+	if n := strings.Count(xml, "<w:vertAlign"); n != 2 {
+		t.Errorf("document XML has %d raised or lowered runs, want 2:\n%s", n, xml)
+	}
+}
+
+func TestRequireReplacement(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte("### Body\n\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "t.docx"), "Dear "+mdword.Placeholder("name")); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-markdown", "doc.md", "-template", "t.docx"}
+	_, stderr, code := runCommand(t, dir, nil, args...)
+	if code != 0 || !strings.Contains(stderr, "the output equals the template") {
+		t.Errorf("exit status %d with %q, want 0 and a warning", code, stderr)
+	}
+	for _, flag := range []string{"-require-replacement", "-strict"} {
+		os.Remove(filepath.Join(dir, "doc.docx"))
+		_, stderr, code := runCommand(t, dir, nil, append(args, flag)...)
+		if code != 1 || !strings.Contains(stderr, "no placeholder of t.docx was replaced") {
+			t.Errorf("%s: exit status %d with %q, want 1 and the guard", flag, code, stderr)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "doc.docx")); err == nil {
+			t.Errorf("%s: document written though no placeholder was replaced", flag)
+		}
+	}
+
+	// one matching key is enough
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte("### Body\n\nb\n\n### Name\n\nAda\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, code := runCommand(t, dir, nil, append(args, "-require-replacement")...); code != 0 {
+		t.Errorf("exit status %d with a replaced placeholder: %s", code, stderr)
+	}
+}
+
+func TestColorSpans(t *testing.T) {
+	markdown := "### Status\n\n{color:Red}late{color}, [#00aa55]{on track} and [teal]{unknown}\n"
+	allow := func(o *mdword.Options) { o.AllowColor = true }
+	outputFile := convert(t, markdown, mdword.Placeholder("status"), allow)
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "late, on track and [teal]{unknown}"; text != want {
+		t.Errorf("got  %q\nwant %q", text, want)
+	}
+	xml := documentXML(t, outputFile)
+	for _, want := range []string{
+		`<w:color w:val="FF0000"/></w:rPr><w:t xml:space="preserve">late</w:t>`,
+		`<w:color w:val="00AA55"/></w:rPr><w:t xml:space="preserve">on track</w:t>`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+
+	text, err = documentText(convert(t, markdown, mdword.Placeholder("status"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{color:Red}late{color}, [#00aa55]{on track} and [teal]{unknown}"; text != want {
+		t.Errorf("without -allow-color got %q, want the spans as text", text)
+	}
+}
+
+func TestDefinitionUnderHeading(t *testing.T) {
+	tests := []struct {
+		name, markdown string
+		want           map[string]string
+	}{
+		{"third-level heading", "### Owner\n: Kim\nmore\n", map[string]string{"owner": "Kim\nmore"}},
+		{"deeper heading", "### Team\n\nt\n\n#### Lead\n: Ada\n", map[string]string{"team": "t", "team-lead": "Ada"}},
+		{"second-level heading", "## Owner\n: ignored\n\nTerm\n: value\n", map[string]string{"owner-term": "value"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// -strict fails on keys given twice, which a definition keyed by the heading was
+			got := parse(t, tt.markdown, func(o *mdword.Options) { o.Strict = true })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConverterStats(t *testing.T) {
+	var log bytes.Buffer
+	c := converter(t, func(o *mdword.Options) { o.Log = &log })
+	other := converter(t, func(o *mdword.Options) { o.Log = io.Discard })
+	doc, err := c.ParseMarkdown(strings.NewReader("### Body\n\nfirst\n\n### Body\n\nsecond\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	templateFile := filepath.Join(t.TempDir(), "template.docx")
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("body")+mdword.Placeholder("empty")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Render(doc, templateFile, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	want := mdword.Counts{Documents: 1, Placeholders: 1, Unfilled: 1, Keys: 1, Words: 1, Warnings: 2}
+	if got := c.Stats(); got != want {
+		t.Errorf("stats %+v, want %+v", got, want)
+	}
+	if got := strings.Count(log.String(), "Warning: "); got != 2 {
+		t.Errorf("log %q holds %d warnings, want 2", log.String(), got)
+	}
+	if got := other.Stats(); got != (mdword.Counts{}) {
+		t.Errorf("stats of an unused converter %+v", got)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte("### Body\n\nb\n\n### Unused\n\nu\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "t.docx"), mdword.Placeholder("body")+mdword.Placeholder("empty")); err != nil {
+		t.Fatal(err)
+	}
+	if _, stderr, code := runCommand(t, dir, nil, "-metrics", "run.prom", "-markdown", "doc.md", "-template", "t.docx"); code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "run.prom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := string(content)
+	for _, want := range []string{
+		"# TYPE markdowntoword_files_processed_total counter\nmarkdowntoword_files_processed_total 1\n",
+		"# TYPE markdowntoword_placeholders_replaced_total counter\nmarkdowntoword_placeholders_replaced_total 1\n",
+		"# TYPE markdowntoword_warnings_total counter\nmarkdowntoword_warnings_total 2\n",
+		"# TYPE markdowntoword_errors_total counter\nmarkdowntoword_errors_total 0\n",
+		"# TYPE markdowntoword_duration_seconds gauge\nmarkdowntoword_duration_seconds ",
+		"# HELP markdowntoword_files_processed_total ",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics do not contain %q:\n%s", want, metrics)
+		}
+	}
+
+	// the metrics are written when the run fails as well
+	os.Remove(filepath.Join(dir, "run.prom"))
+	if _, _, code := runCommand(t, dir, nil, "-metrics", "run.prom", "-markdown", "missing.md", "-template", "t.docx"); code != 1 {
+		t.Errorf("exit status %d for a missing file, want 1", code)
+	}
+	if content, err := os.ReadFile(filepath.Join(dir, "run.prom")); err != nil || !strings.Contains(string(content), "markdowntoword_errors_total 1\n") {
+		t.Errorf("metrics of the failed run %q, %v", content, err)
+	}
+}
+
+func TestDocumentVariables(t *testing.T) {
+	markdown := "### Client\n\nAcme \"Corp\" & Co\n\n### Address\n\n1 Main St\nSpringfield\n"
+	outputFile := convert(t, markdown, mdword.Placeholder("client"), func(o *mdword.Options) { o.DocVars = true })
+	parts := docxParts(t, outputFile)
+	settings := parts["word/settings.xml"]
+	want := `<w:docVars><w:docVar w:name="address" w:val="1 Main St&#xA;Springfield"/><w:docVar w:name="client" w:val="Acme &quot;Corp&quot; &amp; Co"/></w:docVars>`
+	if !strings.Contains(settings, want) {
+		t.Errorf("settings do not contain %s:\n%s", want, settings)
+	}
+	if !strings.Contains(parts["word/_rels/document.xml.rels"], `Target="settings.xml"`) || !strings.Contains(parts["[Content_Types].xml"], "/word/settings.xml") {
+		t.Errorf("settings part not related or typed:\n%s\n%s", parts["word/_rels/document.xml.rels"], parts["[Content_Types].xml"])
+	}
+
+	// filling the output again replaces the variables of the same name and keeps the others
+	c := converter(t, func(o *mdword.Options) { o.DocVarKeys = []string{"client", "missing"} })
+	doc, err := c.ParseMarkdown(strings.NewReader("### Client\n\nInitech\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refilled := filepath.Join(t.TempDir(), "refilled.docx")
+	if err := c.RenderFile(doc, outputFile, refilled); err != nil {
+		t.Fatal(err)
+	}
+	settings = docxParts(t, refilled)["word/settings.xml"]
+	want = `<w:docVars><w:docVar w:name="address" w:val="1 Main St&#xA;Springfield"/><w:docVar w:name="client" w:val="Initech"/></w:docVars>`
+	if !strings.Contains(settings, want) || strings.Count(settings, "<w:docVars>") != 1 {
+		t.Errorf("settings do not contain %s once:\n%s", want, settings)
+	}
+}
+
+func TestMixedListIndentation(t *testing.T) {
+	dir := t.TempDir()
+	markdown := "### Mixed\n\n- a\n  - spaces\n\t- tab\n\t- tab again\n\n### Clean\n\n- a\n\t- tab\n\t\t- tabs\n\n- b\n  - spaces\n"
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte(markdown), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code := runCommand(t, dir, nil, "-markdown", "doc.md", "-emit-data", "data.json")
+	if code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	if want := "Warning: line 5: list indentation mixes tabs and spaces\n"; stderr != want {
+		t.Errorf("stderr %q, want only %q", stderr, want)
+	}
+}
+
+func TestRightToLeft(t *testing.T) {
+	tests := []struct {
+		name, markdown string
+		configure      func(*mdword.Options)
+		rtl            bool
+	}{
+		{"arabic detected", "### Greeting\n\nمرحبا بالعالم\n", nil, true},
+		{"latin", "### Greeting\n\nHello world\n", nil, false},
+		{"flag", "### Greeting\n\nHello world\n", func(o *mdword.Options) { o.RTL = true }, true},
+		{"front matter", "---\ndir: rtl\n---\n### Greeting\n\nHello world\n", nil, true},
+		{"front matter ltr", "---\ndir: ltr\n---\n### Greeting\n\nHello world\n", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xml := documentXML(t, convert(t, tt.markdown, mdword.Placeholder("greeting"), tt.configure))
+			rtl := strings.Contains(xml, "<w:bidi/>") && strings.Contains(xml, "<w:rtl/>")
+			if rtl != tt.rtl {
+				t.Errorf("right to left %v, want %v:\n%s", rtl, tt.rtl, xml)
+			}
+		})
+	}
+}
+
+func TestMath(t *testing.T) {
+	markdown := "### Physics\n\nEnergy $E=mc^2$ costs \\$5, a*b in $a*b$, $5 to $10 and $ x $ stay\n"
+	tests := []struct {
+		mode, want, style string
+	}{
+		{"strip", "Energy E=mc^2 costs $5, a*b in a*b, $5 to $10 and $ x $ stay", ""},
+		{"italic", "Energy E=mc^2 costs $5, a*b in a*b, $5 to $10 and $ x $ stay", `<w:i/></w:rPr><w:t xml:space="preserve">E=mc^2</w:t>`},
+		{"mono", "Energy E=mc^2 costs $5, a*b in a*b, $5 to $10 and $ x $ stay", `<w:rFonts w:ascii="Courier New"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			outputFile := convert(t, markdown, mdword.Placeholder("physics"), func(o *mdword.Options) { o.Math = tt.mode })
+			text, err := documentText(outputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != tt.want {
+				t.Errorf("got  %q\nwant %q", text, tt.want)
+			}
+			if xml := documentXML(t, outputFile); tt.style != "" && !strings.Contains(xml, tt.style) {
+				t.Errorf("document XML does not contain %s:\n%s", tt.style, xml)
+			}
+		})
+	}
+
+	// without -math the spans stay as written, an escaped dollar still loses its backslash
+	text, err := documentText(convert(t, markdown, mdword.Placeholder("physics"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Energy $E=mc^2$ costs \\$5"; !strings.HasPrefix(text, want) {
+		t.Errorf("got %q, want it to start with %q", text, want)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	markdown := "### Body\n\n" + strings.Repeat("The same sentence over and over again. ", 500) + "\n"
+	sizes := make(map[string]int64)
+	for _, compression := range []string{"store", "best"} {
+		outputFile := convert(t, markdown, mdword.Placeholder("body"), func(o *mdword.Options) { o.Compression = compression })
+		info, err := os.Stat(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizes[compression] = info.Size()
+
+		zr, err := zip.OpenReader(outputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range zr.File {
+			if f.Modified.Year() < 2000 {
+				t.Errorf("%s: %s has modified time %v", compression, f.Name, f.Modified)
+			}
+		}
+		zr.Close()
+	}
+	if sizes["best"] >= sizes["store"] {
+		t.Errorf("best is %d bytes, want less than store's %d", sizes["best"], sizes["store"])
+	}
+}
+
+func TestCSVTable(t *testing.T) {
+	dir := t.TempDir()
+	markdownFile := filepath.Join(dir, "doc.md")
+	templateFile := filepath.Join(dir, "template.docx")
+	outputFile := filepath.Join(dir, "output.docx")
+	files := map[string]string{
+		markdownFile:                     "### Prices\n\n{{table: prices.csv}}\n",
+		filepath.Join(dir, "prices.csv"): "Item,Price\nApple,1.20\n\"Pear, ripe\",0.90\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("prices")); err != nil {
+		t.Fatal(err)
+	}
+	c := converter(t, func(o *mdword.Options) { o.TableAlign = "lr" })
+	doc, err := c.ParseMarkdownFile(markdownFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RenderFile(doc, templateFile, outputFile); err != nil {
+		t.Fatal(err)
+	}
+
+	xml := documentXML(t, outputFile)
+	if cols := strings.Count(xml, "<w:gridCol "); cols != 2 {
+		t.Errorf("table has %d columns, want 2", cols)
+	}
+	if rows := strings.Count(xml, "<w:tr>"); rows != 3 {
+		t.Errorf("table has %d rows, want 3", rows)
+	}
+	for _, want := range []string{
+		`<w:b/></w:rPr><w:t xml:space="preserve">Item</w:t>`,
+		`<w:pPr><w:jc w:val="right"/></w:pPr><w:r><w:t xml:space="preserve">0.90</w:t>`,
+		`<w:t xml:space="preserve">Pear, ripe</w:t>`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+}
+
+func TestWordComments(t *testing.T) {
+	markdown := "### Summary\n\n<!-- review: check this -->\nRevenue grew <!-- source? -->by 5%.\n"
+	outputFile := convert(t, markdown, mdword.Placeholder("summary"), func(o *mdword.Options) { o.CommentsAsWordComments = true })
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Revenue grew by 5%."; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+
+	parts := docxParts(t, outputFile)
+	for part, wants := range map[string][]string{
+		"word/document.xml": {
+			`<w:commentRangeStart w:id="0"/><w:r><w:t xml:space="preserve">Revenue grew </w:t></w:r><w:commentRangeEnd w:id="0"/>`,
+			`<w:commentRangeStart w:id="1"/><w:r><w:t xml:space="preserve">by 5%.</w:t></w:r><w:commentRangeEnd w:id="1"/>`,
+			`<w:commentReference w:id="1"/>`,
+		},
+		"word/comments.xml":            {`w:id="0"`, "review: check this", `w:id="1"`, "source?"},
+		"[Content_Types].xml":          {`PartName="/word/comments.xml"`},
+		"word/_rels/document.xml.rels": {`Target="comments.xml"`},
+	} {
+		for _, want := range wants {
+			if !strings.Contains(parts[part], want) {
+				t.Errorf("%s does not contain %s:\n%s", part, want, parts[part])
+			}
+		}
+	}
+
+	// without the flag comments are dropped
+	outputFile = convert(t, markdown, mdword.Placeholder("summary"), nil)
+	if _, ok := docxParts(t, outputFile)["word/comments.xml"]; ok {
+		t.Error("comments part written without -comments-as-word-comments")
+	}
+}
+
+func TestSafe(t *testing.T) {
+	dir := t.TempDir()
+	markdownFile := filepath.Join(dir, "doc.md")
+	templateFile := filepath.Join(dir, "template.docx")
+	files := map[string]string{
+		markdownFile:                     "### Body\n\n{{table: secret.csv}}\n\n![diagram](secret.png)\n",
+		filepath.Join(dir, "secret.csv"): "Name,Password\nadmin,hunter2\n",
+		filepath.Join(dir, "secret.png"): "not read",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("body")); err != nil {
+		t.Fatal(err)
+	}
+	render := func(safe bool) string {
+		c := converter(t, func(o *mdword.Options) { o.Safe = safe })
+		doc, err := c.ParseMarkdownFile(markdownFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputFile := filepath.Join(t.TempDir(), "output.docx")
+		if err := c.RenderFile(doc, templateFile, outputFile); err != nil {
+			t.Fatal(err)
+		}
+		return documentXML(t, outputFile)
+	}
+
+	xml := render(true)
+	for _, want := range []string{"[table not included: secret.csv]", "[image not included: secret.png]"} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+	if strings.Contains(xml, "hunter2") || strings.Contains(xml, "<w:tbl>") {
+		t.Errorf("table file read with -safe:\n%s", xml)
+	}
+
+	if xml := render(false); !strings.Contains(xml, "hunter2") {
+		t.Errorf("table file not read without -safe:\n%s", xml)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	markdown := "### Code\n\n```go\n// greet says hello\nfunc greet() string {\n\treturn \"hello\"\n}\n```\n"
+	xml := documentXML(t, convert(t, markdown, mdword.Placeholder("code"), func(o *mdword.Options) { o.Highlight = true }))
+	colors := make(map[string]string)
+	runRegex := regexp.MustCompile(`<w:r><w:rPr>(.*?)</w:rPr><w:t xml:space="preserve">(.*?)</w:t></w:r>`)
+	colorRegex := regexp.MustCompile(`<w:color w:val="([0-9A-F]{6})"/>`)
+	for _, m := range runRegex.FindAllStringSubmatch(xml, -1) {
+		if !strings.Contains(m[1], "Courier New") {
+			t.Errorf("run %q is not monospaced", m[2])
+		}
+		if c := colorRegex.FindStringSubmatch(m[1]); c != nil {
+			colors[strings.TrimSpace(m[2])] = c[1]
+		}
+	}
+	keyword, str, comment := colors["func"], colors["&quot;hello&quot;"], colors["// greet says hello"]
+	if keyword == "" || str == "" || comment == "" {
+		t.Fatalf("keyword, string and comment are not all colored: %v\n%s", colors, xml)
+	}
+	if keyword == str || keyword == comment || str == comment {
+		t.Errorf("keyword %s, string %s and comment %s colors are not distinct", keyword, str, comment)
+	}
+
+	// unknown languages are plain monospace
+	xml = documentXML(t, convert(t, "### Code\n\n```nosuchlanguage\nfunc greet()\n```\n", mdword.Placeholder("code"), func(o *mdword.Options) { o.Highlight = true }))
+	if !strings.Contains(xml, "Courier New") || strings.Contains(xml, "<w:color ") {
+		t.Errorf("unknown language is not plain monospace:\n%s", xml)
+	}
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []struct {
+		name string
+		vars map[string]string
+		body string
+		want string
+	}{
+		{"presence true", map[string]string{"draft": "yes"}, "a\n{{#if draft}}\nDRAFT\n{{/if}}\nb", "a\nDRAFT\nb"},
+		{"presence false", map[string]string{"draft": ""}, "a\n{{#if draft}}\nDRAFT\n{{/if}}\nb", "a\nb"},
+		{"equality true", map[string]string{"env": "prod"}, `{{#if env == "prod"}}live{{/if}} site`, "live site"},
+		{"equality false", map[string]string{"env": "test"}, `{{#if env == "prod"}}live{{/if}} site`, "site"},
+		{"undefined presence", nil, "a {{#if missing}}x{{/if}}b", "a b"},
+		{"undefined equality", nil, `a {{#if missing == ""}}x{{/if}}b`, "a b"},
+		{"else taken", map[string]string{"env": "test"}, `{{#if env == "prod"}}live{{else}}staging{{/if}}`, "staging"},
+		{"else skipped", map[string]string{"env": "prod"}, `{{#if env == "prod"}}live{{else}}staging{{/if}}`, "live"},
+		{"else on own lines", nil, "{{#if draft}}\ndraft\n{{else}}\nfinal\n{{/if}}\nend", "final\nend"},
+		{
+			name: "nested",
+			vars: map[string]string{"env": "prod", "region": "eu"},
+			body: `{{#if env == "prod"}}prod {{#if region == "us"}}us{{else}}other{{/if}} {{#if beta}}beta{{/if}}{{else}}test{{/if}}`,
+			want: "prod other",
+		},
+		{
+			name: "nested in a dropped block",
+			vars: map[string]string{"region": "us"},
+			body: `{{#if env == "prod"}}{{#if region == "us"}}us{{else}}other{{/if}}{{else}}none{{/if}}`,
+			want: "none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parse(t, "### Body\n\n"+tt.body+"\n", func(o *mdword.Options) { o.Vars = tt.vars })
+			if got["body"] != tt.want {
+				t.Errorf("got %q, want %q", got["body"], tt.want)
+			}
+		})
+	}
+
+	// tags which do not pair up are only reported, unless -strict
+	for _, body := range []string{"{{#if draft}}x", "x{{/if}}", "x{{else}}y", "{{#if a}}x{{else}}y{{else}}z{{/if}}"} {
+		markdown := "### Body\n\n" + body + "\n"
+		if _, err := converter(t, nil).ParseMarkdown(strings.NewReader(markdown)); err != nil {
+			t.Errorf("%q: %v", body, err)
+		}
+		_, err := converter(t, func(o *mdword.Options) { o.Strict = true }).ParseMarkdown(strings.NewReader(markdown))
+		if err == nil || !strings.Contains(err.Error(), "conditional blocks do not pair up") {
+			t.Errorf("%q with -strict: got error %v", body, err)
+		}
+	}
+}
+
+func TestEncodingWarnings(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// ’ and é saved as UTF-8 after being read as Windows-1252
+		"mojibake.md": "### Note\n\nItâ€™s a cafÃ©\n",
+		// é saved as Windows-1252
+		"latin.md": "### Note\n\ncaf\xe9\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, stderr, code := runCommand(t, dir, nil, "-markdown", "mojibake.md", "-emit-data", "-")
+	if code != 0 || !strings.Contains(stderr, `line 3: "â€™" looks like a garbled "’"`) || !strings.Contains(stderr, "-encoding") {
+		t.Errorf("mojibake: exit %d, stderr %q", code, stderr)
+	}
+
+	_, stderr, code = runCommand(t, dir, nil, "-markdown", "latin.md", "-emit-data", "-")
+	if code != 0 || !strings.Contains(stderr, "line 3: the markdown is not valid UTF-8") || !strings.Contains(stderr, "-encoding windows-1252") {
+		t.Errorf("invalid UTF-8: exit %d, stderr %q", code, stderr)
+	}
+
+	stdout, stderr, code := runCommand(t, dir, nil, "-markdown", "latin.md", "-encoding", "windows-1252", "-emit-data", "-")
+	if code != 0 || !strings.Contains(stdout, `"note": "café"`) || strings.Contains(stderr, "Warning") {
+		t.Errorf("-encoding windows-1252: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+
+	_, stderr, code = runCommand(t, dir, nil, "-markdown", "latin.md", "-encoding", "klingon", "-emit-data", "-")
+	if code == 0 || !strings.Contains(stderr, `unknown encoding "klingon"`) {
+		t.Errorf("unknown encoding: exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestNestedEmphasis(t *testing.T) {
+	markdown := "### Body\n\n**bold _both_ bold** *it **both** it* _it __both__ it_ __bold *both* bold__ ~~gone **both**~~ snake_case_name __init__\n"
+	xml := documentXML(t, convert(t, markdown, mdword.Placeholder("body"), nil))
+	var got []string
+	runRegex := regexp.MustCompile(`<w:r>(?:<w:rPr>(.*?)</w:rPr>)?<w:t xml:space="preserve">(.*?)</w:t></w:r>`)
+	for _, m := range runRegex.FindAllStringSubmatch(xml, -1) {
+		style := ""
+		for _, s := range []struct{ tag, name string }{{"<w:b/>", "b"}, {"<w:i/>", "i"}, {"<w:strike/>", "s"}} {
+			if strings.Contains(m[1], s.tag) {
+				style += s.name
+			}
+		}
+		got = append(got, style+":"+m[2])
+	}
+	want := []string{
+		"b:bold ", "bi:both", "b: bold", ": ",
+		"i:it ", "bi:both", "i: it", ": ",
+		"i:it ", "bi:both", "i: it", ": ",
+		"b:bold ", "bi:both", "b: bold", ": ",
+		"s:gone ", "bs:both", ": snake_case_name ", "b:init",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestStrictConditionVariables(t *testing.T) {
+	dir := t.TempDir()
+	markdown := "### Body\n\n{{#if env == \"prod\"}}live{{else}}staging{{/if}}\n"
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte(markdown), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "template.docx"), mdword.Placeholder("body")); err != nil {
+		t.Fatal(err)
+	}
+	run := func(set string) (string, int) {
+		_, stderr, code := runCommand(t, dir, nil, "-markdown", "doc.md", "-template", "template.docx", "-output", "out.docx", "-strict", "-set", set)
+		return stderr, code
+	}
+
+	// a variable only tested by a condition is used
+	if stderr, code := run("env=prod"); code != 0 || strings.Contains(stderr, "keys matching no placeholder") {
+		t.Errorf("-set env=prod -strict: exit %d, stderr %q", code, stderr)
+	}
+	text, err := documentText(filepath.Join(dir, "out.docx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "live" {
+		t.Errorf("got %q, want %q", text, "live")
+	}
+
+	if stderr, code := run("region=eu"); code == 0 || !strings.Contains(stderr, "keys matching no placeholder of template.docx: region") {
+		t.Errorf("-set region=eu -strict: exit %d, stderr %q", code, stderr)
+	}
+}
+
+func TestCRLF(t *testing.T) {
+	lf := "---\nversion: 2\n---\n## Intro\n\n### Summary\n\nFirst line\nsecond **bold** line\n\n- one\n- two\n\n```\ncode  \n```\n\n| A | B |\n|---|---|\n| 1 | 2 |\n\nOwner\n: Kim\n\n### Notes  \n\n> quoted\n"
+	crlf := strings.ReplaceAll(lf, "\n", "\r\n")
+
+	want := parse(t, lf, nil)
+	got := parse(t, crlf, nil)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CRLF data %q\nLF data %q", got, want)
+	}
+	for key, value := range got {
+		if strings.Contains(key+value, "\r") {
+			t.Errorf("%q: %q contains a carriage return", key, value)
+		}
+	}
+
+	text := mdword.Placeholder("intro-summary") + mdword.Placeholder("intro-notes") + mdword.Placeholder("intro-owner")
+	wantXML := documentXML(t, convert(t, lf, text, nil))
+	if gotXML := documentXML(t, convert(t, crlf, text, nil)); gotXML != wantXML {
+		t.Errorf("CRLF document\n%s\nLF document\n%s", gotXML, wantXML)
+	}
+}
+
+func TestCodeBlockAsterisks(t *testing.T) {
+	markdown := "### Code\n\n```\nint *p = *q * 2; /* **not bold** */\n* not a list item\n_not_italic_ ~~kept~~\n```\n\nUse `*ptr` and **bold**.\n"
+	outputFile := convert(t, markdown, mdword.Placeholder("code"), nil)
+	xml := documentXML(t, outputFile)
+	for _, line := range []string{"int *p = *q * 2; /* **not bold** */", "* not a list item", "_not_italic_ ~~kept~~"} {
+		want := `<w:t xml:space="preserve">` + line + `</w:t>`
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+	if got := strings.Count(xml, "<w:b/>"); got != 1 {
+		t.Errorf("document XML has %d bold runs, want only the one outside the code:\n%s", got, xml)
+	}
+	if !strings.Contains(xml, `<w:t xml:space="preserve">*ptr</w:t>`) || strings.Contains(xml, "<w:i/>") || strings.Contains(xml, "•") {
+		t.Errorf("code is styled as markdown:\n%s", xml)
+	}
+}
+
+func TestImageCaption(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	markdown := "### Titled\n\n![Alt one](diagram.png \"Title one\")\n\n### Untitled\n\n![Alt two](diagram.png)\n"
+	files := map[string][]byte{"diagram.png": buf.Bytes(), "doc.md": []byte(markdown)}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	templateFile := filepath.Join(dir, "template.docx")
+	outputFile := filepath.Join(dir, "output.docx")
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("titled")+mdword.Placeholder("untitled")); err != nil {
+		t.Fatal(err)
+	}
+	if err := converter(t, nil).ConvertFile(filepath.Join(dir, "doc.md"), templateFile, outputFile, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	xml := documentXML(t, outputFile)
+	caption := `<w:pStyle w:val="Caption"/></w:pPr><w:r><w:t xml:space="preserve">%s</w:t>`
+	for _, want := range []string{fmt.Sprintf(caption, "Title one"), fmt.Sprintf(caption, "Alt two"), `descr="Alt one"`, `descr="Alt two"`} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+	if strings.Contains(xml, fmt.Sprintf(caption, "Alt one")) {
+		t.Errorf("the alt text is the caption of an image with a title:\n%s", xml)
+	}
+}
+
+func TestCoverPage(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	markdown := "---\ntitle: Annual Report\nsubtitle: Fiscal year 2026\nauthor: Kim Lee\ndate: 2026-10-15\nlogo: assets/logo.png\n---\n\n### Body\n\nThe report.\n"
+	if err := os.Mkdir(filepath.Join(dir, "assets"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{"assets/logo.png": buf.Bytes(), "report.md": []byte(markdown)}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "t.docx"), "Template "+mdword.Placeholder("body")); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code := runCommand(t, dir, nil, "-cover", "-markdown", "report.md", "-template", "t.docx", "-output", "out.docx")
+	if code != 0 {
+		t.Fatalf("exit status %d: %s", code, stderr)
+	}
+	if strings.Contains(stderr, "keys matching no placeholder") || strings.Contains(stderr, "image") {
+		t.Errorf("stderr %q", stderr)
+	}
+	outputFile := filepath.Join(dir, "out.docx")
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Annual ReportFiscal year 2026Kim Lee2026-10-15Template The report."; text != want {
+		t.Errorf("got  %q\nwant %q", text, want)
+	}
+
+	parts := docxParts(t, outputFile)
+	logos := 0
+	for name, content := range parts {
+		if strings.HasPrefix(name, "word/media/") && content == buf.String() {
+			logos++
+		}
+	}
+	if logos != 1 {
+		t.Errorf("the output holds %d logo image parts, want 1", logos)
+	}
+	xml := parts["word/document.xml"]
+	for _, want := range []string{
+		`<w:body><w:p><w:pPr><w:jc w:val="center"/></w:pPr><w:r><w:drawing>`,
+		`<w:pPr><w:pStyle w:val="Title"/><w:jc w:val="center"/></w:pPr><w:r><w:t xml:space="preserve">Annual Report</w:t>`,
+		`<w:pPr><w:pStyle w:val="Subtitle"/><w:jc w:val="center"/></w:pPr>`,
+		`2026-10-15</w:t></w:r></w:p><w:p><w:r><w:br w:type="page"/></w:r></w:p>`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+
+	// without a title there is no cover page
+	if err := os.WriteFile(filepath.Join(dir, "untitled.md"), []byte("### Body\n\nThe report.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code = runCommand(t, dir, nil, "-cover", "-markdown", "untitled.md", "-template", "t.docx", "-output", "untitled.docx")
+	if code != 0 || !strings.Contains(stderr, "-cover: no title key") {
+		t.Errorf("exit status %d: %s", code, stderr)
+	}
+	if text, err := documentText(filepath.Join(dir, "untitled.docx")); err != nil || text != "Template The report." {
+		t.Errorf("got %q, %v", text, err)
+	}
+}
+
+func TestPDFErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte("### Body\n\ntext\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSelfTestTemplate(filepath.Join(dir, "template.docx"), mdword.Placeholder("body")); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"no converter", []string{"-output", "out.docx", "-pdf"}, "PDF output needs LibreOffice, install it so that soffice or libreoffice is on PATH"},
+		{"pdf output", []string{"-output", "out.pdf"}, "PDF output needs LibreOffice"},
+		{"safe", []string{"-output", "out.docx", "-pdf", "-safe"}, "-pdf runs LibreOffice and cannot be used with -safe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-markdown", "doc.md", "-template", "template.docx"}, tt.args...)
+			_, stderr, code := runCommand(t, dir, []string{"PATH="}, args...)
+			if code == 0 || !strings.Contains(stderr, tt.want) {
+				t.Errorf("exit %d, stderr %q, want %q", code, stderr, tt.want)
+			}
+			for _, name := range []string{"out.docx", "out.pdf"} {
+				if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+					t.Errorf("%s written", name)
+				}
+			}
+		})
+	}
+}
+
+func TestDocumentHook(t *testing.T) {
+	var seen string
+	hook := func(doc *docx.Document) error {
+		seen = string(doc.GetFile("word/document.xml"))
+		added := `<w:p><w:r><w:t>Added by the hook</w:t></w:r></w:p></w:body>`
+		return doc.SetFile("word/document.xml", []byte(strings.Replace(seen, "</w:body>", added, 1)))
+	}
+	outputFile := convert(t, "### Name\n\nKim\n\n### Body\n\n**first**\n", mdword.Placeholder("name")+"|"+mdword.Placeholder("body"),
+		func(o *mdword.Options) { o.DocumentHook = hook })
+	text, err := documentText(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the hook sees plain values replaced, the bold value is rendered after it
+	if want := "Kim|firstAdded by the hook"; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+	if !strings.Contains(seen, "Kim|") || strings.Contains(seen, "first") {
+		t.Errorf("hook saw %s", seen)
+	}
+
+	failing := func(*docx.Document) error { return fmt.Errorf("no way") }
+	c := converter(t, func(o *mdword.Options) { o.DocumentHook = failing })
+	doc, err := c.ParseMarkdown(strings.NewReader("### Name\n\nKim\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	templateFile := filepath.Join(t.TempDir(), "template.docx")
+	if err := writeSelfTestTemplate(templateFile, mdword.Placeholder("name")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Render(doc, templateFile, io.Discard); err == nil || err.Error() != "document hook: no way" {
+		t.Errorf("got error %v, want the hook's", err)
+	}
+}
+
+func TestImageReferences(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	markdown := "### Figure\n\n![Sales chart][Chart]\n\n### Missing\n\nSee ![Org chart][org] here\n\n" +
+		"### Code\n\n```\n![kept][chart]\n[chart]: kept.png\n```\n\n[chart]: <my chart.png> \"Figure 1\"\n"
+	files := map[string][]byte{"my chart.png": buf.Bytes(), "doc.md": []byte(markdown)}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	text := mdword.Placeholder("figure") + "|" + mdword.Placeholder("missing") + "|" + mdword.Placeholder("code")
+	if err := writeSelfTestTemplate(filepath.Join(dir, "template.docx"), text); err != nil {
+		t.Fatal(err)
+	}
+	_, stderr, code := runCommand(t, dir, nil, "-markdown", "doc.md", "-template", "template.docx", "-output", "out.docx")
+	if code != 0 || !strings.Contains(stderr, `line 7: image reference [org] is not defined, keeping the alt text "Org chart"`) {
+		t.Errorf("exit %d, stderr %q", code, stderr)
+	}
+
+	outputFile := filepath.Join(dir, "out.docx")
+	xml := documentXML(t, outputFile)
+	for _, want := range []string{"<w:drawing>", `descr="Sales chart"`, ">Figure 1</w:t>", "See Org chart here", "![kept][chart]", "[chart]: kept.png"} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("document XML does not contain %s:\n%s", want, xml)
+		}
+	}
+	if strings.Contains(xml, "[chart]: &lt;") || strings.Contains(xml, "![Sales chart]") {
+		t.Errorf("reference definition or image left as text:\n%s", xml)
+	}
+	media := 0
+	for name := range docxParts(t, outputFile) {
+		if strings.HasPrefix(name, "word/media/") {
+			media++
+		}
+	}
+	if media != 1 {
+		t.Errorf("%d media parts written, want 1", media)
+	}
+}
@@ -0,0 +1,113 @@
+package mdword
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/lukasjarosch/go-docx"
+)
+
+// CheckTemplate lints the template placeholders against the data keys and returns a
+// description of every problem found.
+func (c *Converter) CheckTemplate(placeholders []string, data map[string]string) []string {
+	folded := make(map[string]string, len(data))
+	for key := range data {
+		folded[strings.ToLower(key)] = key
+	}
+
+	seen := make(map[string]bool)
+	sorted := append([]string(nil), placeholders...)
+	sort.Strings(sorted)
+	var problems []string
+	for _, placeholder := range sorted {
+		if seen[placeholder] {
+			continue
+		}
+		seen[placeholder] = true
+
+		key := placeholder
+		if m := truncatedPlaceholderRegex.FindStringSubmatch(placeholder); m != nil {
+			key = m[1]
+		}
+		if _, ok := data[key]; ok {
+			continue
+		}
+		if match, ok := folded[strings.ToLower(key)]; ok {
+			problems = append(problems, fmt.Sprintf("placeholder %s differs only by case from key %q and will not be filled, write it as %s",
+				c.Placeholder(placeholder), match, c.Placeholder(strings.Replace(placeholder, key, match, 1))))
+		}
+	}
+	return problems
+}
+
+// LoadPlaceholderList reads a contract of required placeholders, one per line. Blank lines
+// and lines starting with # are ignored and the delimiters of c around a name are optional.
+func (c *Converter) LoadPlaceholderList(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, strings.TrimRight(strings.TrimLeft(line, string(c.open)), string(c.close)))
+	}
+	return names, nil
+}
+
+// MissingPlaceholders returns the required placeholders the template does not contain.
+func MissingPlaceholders(required, placeholders []string) []string {
+	present := make(map[string]bool, len(placeholders))
+	for _, placeholder := range placeholders {
+		present[placeholder] = true
+	}
+	var missing []string
+	for _, name := range required {
+		if !present[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// unmatchedKeys returns the placeholders of the template which get no value and the data
+// keys which fill no placeholder, both sorted. Keys used for the document title, language
+// or direction, as document variables in docVars, as variables of {{#if}} conditions or
+// among the other keys used, like those of the -cover page, and keys only used truncated
+// like {summary:80}, count as used.
+func unmatchedKeys(placeholders []string, data map[string]string, replaceMap docx.PlaceholderMap, docVars map[string]string, conditions map[string]bool, keys []string) (unfilled, unused []string) {
+	used := map[string]bool{titleKey: true, langKey: true, dirKey: true}
+	for key := range docVars {
+		used[key] = true
+	}
+	for key := range conditions {
+		used[key] = true
+	}
+	for _, key := range keys {
+		used[key] = true
+	}
+	seen := make(map[string]bool)
+	for _, placeholder := range placeholders {
+		used[placeholder] = true
+		if m := truncatedPlaceholderRegex.FindStringSubmatch(placeholder); m != nil {
+			used[m[1]] = true
+		}
+		if _, ok := replaceMap[placeholder]; !ok && !seen[placeholder] {
+			unfilled = append(unfilled, placeholder)
+		}
+		seen[placeholder] = true
+	}
+	for key := range data {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(unfilled)
+	sort.Strings(unused)
+	return unfilled, unused
+}
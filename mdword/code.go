@@ -0,0 +1,52 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// codeSpanRegex matches inline code spans delimited by single or double backticks, the
+// latter allowing single backticks within the code.
+var codeSpanRegex = regexp.MustCompile("``(.+?)``|`([^`\n]+)`")
+
+// hasCode reports whether text contains inline code spans or fenced code blocks.
+func hasCode(text string) bool {
+	return strings.Contains(text, "`") && (strings.Contains(text, "```") || codeSpanRegex.MatchString(text))
+}
+
+// codeRuns splits the plain text runs at inline code spans, dropping the backticks and
+// setting the code in the monospaced font. Later processing leaves monospaced runs alone, so
+// code is kept verbatim.
+func codeRuns(runs []textRun) []textRun {
+	var result []textRun
+	for _, run := range runs {
+		if run.checkbox != noCheckbox || run.columnBreak || run.monospace {
+			result = append(result, run)
+			continue
+		}
+		last := 0
+		for _, m := range codeSpanRegex.FindAllStringSubmatchIndex(run.text, -1) {
+			if m[0] > last {
+				result = append(result, run.withText(run.text[last:m[0]]))
+			}
+			var code string
+			if m[2] >= 0 {
+				code = run.text[m[2]:m[3]]
+			} else {
+				code = run.text[m[4]:m[5]]
+			}
+			// a space on both sides separates the code from backticks and is not part of it
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' {
+				code = code[1 : len(code)-1]
+			}
+			span := run.withText(code)
+			span.monospace = true
+			result = append(result, span)
+			last = m[1]
+		}
+		if last < len(run.text) || last == 0 {
+			result = append(result, run.withText(run.text[last:]))
+		}
+	}
+	return result
+}
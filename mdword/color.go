@@ -0,0 +1,72 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// colorSpanRegex matches the inline color spans understood with -allow-color, either
+// {color:red}text{color} or [red]{text}.
+var colorSpanRegex = regexp.MustCompile(`\{color:([#\w]+)\}(.*?)\{color\}|\[([#\w]+)\]\{([^{}]*)\}`)
+
+var hexColorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// namedColors maps the supported color names to their RRGGBB value.
+var namedColors = map[string]string{
+	"black":  "000000",
+	"white":  "FFFFFF",
+	"gray":   "808080",
+	"grey":   "808080",
+	"red":    "FF0000",
+	"orange": "FFA500",
+	"yellow": "FFFF00",
+	"green":  "008000",
+	"blue":   "0000FF",
+	"purple": "800080",
+}
+
+// colorValue returns the RRGGBB value of a color name or #RRGGBB code.
+func colorValue(name string) (string, bool) {
+	if hexColorRegex.MatchString(name) {
+		return strings.ToUpper(name[1:]), true
+	}
+	value, ok := namedColors[strings.ToLower(name)]
+	return value, ok
+}
+
+// colorRuns splits the plain text runs at color spans, giving the enclosed text its color.
+// Spans with an unknown color are left as they are with a warning to warnf.
+func colorRuns(runs []textRun, warnf func(format string, args ...interface{})) []textRun {
+	var result []textRun
+	for _, run := range runs {
+		if run.checkbox != noCheckbox || run.columnBreak || run.monospace {
+			result = append(result, run)
+			continue
+		}
+		last := 0
+		for _, m := range colorSpanRegex.FindAllStringSubmatchIndex(run.text, -1) {
+			var name, text string
+			if m[2] >= 0 {
+				name, text = run.text[m[2]:m[3]], run.text[m[4]:m[5]]
+			} else {
+				name, text = run.text[m[6]:m[7]], run.text[m[8]:m[9]]
+			}
+			color, ok := colorValue(name)
+			if !ok {
+				warnf("unknown color %q, use one of the named colors or #RRGGBB", name)
+				continue
+			}
+			if m[0] > last {
+				result = append(result, run.withText(run.text[last:m[0]]))
+			}
+			span := run.withText(text)
+			span.color = color
+			result = append(result, span)
+			last = m[1]
+		}
+		if last < len(run.text) || last == 0 {
+			result = append(result, run.withText(run.text[last:]))
+		}
+	}
+	return result
+}
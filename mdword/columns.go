@@ -0,0 +1,32 @@
+package mdword
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// columnBreakRegex matches the marker line starting a new column.
+var columnBreakRegex = regexp.MustCompile(`(?mi)^<!--\s*column-break\s*-->$`)
+
+// sectionBreak ends a section of the document. A value with column breaks is enclosed in
+// continuous section breaks so that it can be laid out in columns of its own: the first
+// ends the preceding content with the layout of the body, the second ends the value with
+// the given number of columns.
+type sectionBreak struct {
+	columns int
+}
+
+func (s *sectionBreak) writeXML(b *strings.Builder, ctx *blockContext) {
+	sectPr := ctx.rend.bodySectPr
+	if sectPr == "" {
+		// only the main document has sections
+		return
+	}
+	sectPr = setSectionProps(sectPr, `<w:type w:val="continuous"/>`)
+	if s.columns > 0 {
+		sectPr = setSectionProps(sectPr, `<w:cols w:num="`+strconv.Itoa(s.columns)+`" w:space="720"/>`)
+	}
+	ctx.rend.sections = true
+	b.WriteString("<w:p>" + setParagraphProps(ctx.pPr, sectPr) + "</w:p>")
+}
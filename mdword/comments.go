@@ -0,0 +1,100 @@
+package mdword
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// htmlCommentRegex matches the HTML comments turned into Word comments with
+// -comments-as-word-comments.
+var htmlCommentRegex = regexp.MustCompile(`(?s)<!--\s*(.*?)\s*-->`)
+
+var commentIDRegex = regexp.MustCompile(`<w:comment [^>]*w:id="(\d+)"`)
+
+const (
+	relTypeComments     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/comments"
+	contentTypeComments = "application/vnd.openxmlformats-officedocument.wordprocessingml.comments+xml"
+)
+
+// commentRuns removes HTML comments from the plain text runs and anchors each to the text
+// following it. A comment on a line of its own takes its line break along, and a comment
+// with no text after it is anchored where it stood.
+func commentRuns(runs []textRun) []textRun {
+	var result []textRun
+	for _, run := range runs {
+		if run.checkbox != noCheckbox || run.columnBreak {
+			result = append(result, run)
+			continue
+		}
+		var pending []string
+		last := 0
+		for _, m := range htmlCommentRegex.FindAllStringSubmatchIndex(run.text, -1) {
+			if m[0] > last {
+				result = append(result, anchored(run.withText(run.text[last:m[0]]), pending))
+				pending = nil
+			}
+			pending = append(pending, run.text[m[2]:m[3]])
+			last = m[1]
+			if (m[0] == 0 || run.text[m[0]-1] == '\n') && strings.HasPrefix(run.text[last:], "\n") {
+				last++
+			}
+		}
+		if last < len(run.text) || last == 0 || pending != nil {
+			result = append(result, anchored(run.withText(run.text[last:]), pending))
+		}
+	}
+	return result
+}
+
+func anchored(run textRun, comments []string) textRun {
+	if len(comments) > 0 {
+		run.comment = strings.Join(comments, "\n")
+	}
+	return run
+}
+
+// addComment registers the text of a Word comment and returns its id.
+func (r *renderer) addComment(text string) string {
+	r.comments = append(r.comments, text)
+	return strconv.Itoa(r.commentBase + len(r.comments) - 1)
+}
+
+// firstCommentID returns the first comment id not used by the comments of the template.
+func firstCommentID(pkg *docxPackage) int {
+	next := 0
+	for _, m := range commentIDRegex.FindAllStringSubmatch(string(pkg.parts["word/comments.xml"]), -1) {
+		if id, err := strconv.Atoi(m[1]); err == nil && id >= next {
+			next = id + 1
+		}
+	}
+	return next
+}
+
+// addComments writes the registered comments into the comments part, creating it if the
+// template has none.
+func addComments(pkg *docxPackage, r *renderer) {
+	const commentsName = "word/comments.xml"
+	var b strings.Builder
+	for i, text := range r.comments {
+		b.WriteString(`<w:comment w:id="` + strconv.Itoa(r.commentBase+i) + `" w:author="` + revisionAuthor + `"`)
+		if r.date != "" {
+			b.WriteString(` w:date="` + r.date + `"`)
+		}
+		b.WriteString(">")
+		for _, line := range strings.Split(text, "\n") {
+			b.WriteString("<w:p><w:r>")
+			writeText(&b, line)
+			b.WriteString("</w:r></w:p>")
+		}
+		b.WriteString("</w:comment>")
+	}
+
+	if existing, ok := pkg.parts[commentsName]; ok {
+		pkg.parts[commentsName] = []byte(strings.Replace(string(existing), "</w:comments>", b.String()+"</w:comments>", 1))
+		return
+	}
+	pkg.addPart(commentsName, []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<w:comments xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">`+b.String()+`</w:comments>`), contentTypeComments)
+	pkg.addRelationship(relTypeComments, "comments.xml")
+}
@@ -0,0 +1,85 @@
+package mdword
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// conditionalRegex matches the {{#if name}}, {{#if name == "value"}}, {{else}} and {{/if}}
+// tags of conditional blocks.
+var conditionalRegex = regexp.MustCompile(`\{\{#if\s+([\w.-]+)\s*(?:==\s*"([^"]*)"\s*)?\}\}|\{\{(else|/if)\s*\}\}`)
+
+// condition is an open conditional block.
+type condition struct {
+	holds, inElse bool
+}
+
+// applyConditionals keeps the content of conditional blocks whose condition holds for vars
+// and drops the others. {{#if name}} holds if the variable is set to a non-empty value and
+// {{#if name == "value"}} if it is set to exactly that value; an {{else}} part is kept when
+// the condition does not hold. Blocks may nest, and a tag on a line of its own is removed
+// along with its line. Tags which do not pair up are returned as problems.
+func applyConditionals(markdown string, vars map[string]string) (string, []string) {
+	var b strings.Builder
+	var stack []condition
+	var problems []string
+	keep := func() bool {
+		for _, c := range stack {
+			if c.holds == c.inElse {
+				return false
+			}
+		}
+		return true
+	}
+
+	last := 0
+	for _, m := range conditionalRegex.FindAllStringSubmatchIndex(markdown, -1) {
+		if keep() {
+			b.WriteString(markdown[last:m[0]])
+		}
+		last = m[1]
+		if (m[0] == 0 || markdown[m[0]-1] == '\n') && strings.HasPrefix(markdown[last:], "\n") {
+			last++
+		}
+
+		switch {
+		case m[6] >= 0 && markdown[m[6]:m[7]] == "else":
+			if len(stack) == 0 || stack[len(stack)-1].inElse {
+				problems = append(problems, "{{else}} without a matching {{#if}}")
+				continue
+			}
+			stack[len(stack)-1].inElse = true
+		case m[6] >= 0:
+			if len(stack) == 0 {
+				problems = append(problems, "{{/if}} without a matching {{#if}}")
+				continue
+			}
+			stack = stack[:len(stack)-1]
+		case m[4] < 0:
+			stack = append(stack, condition{holds: vars[markdown[m[2]:m[3]]] != ""})
+		default:
+			value, ok := vars[markdown[m[2]:m[3]]]
+			stack = append(stack, condition{holds: ok && value == markdown[m[4]:m[5]]})
+		}
+	}
+	if keep() {
+		b.WriteString(markdown[last:])
+	}
+	if len(stack) > 0 {
+		problems = append(problems, fmt.Sprintf("%d {{#if}} blocks are not closed with {{/if}}", len(stack)))
+	}
+	return b.String(), problems
+}
+
+// conditionVariables returns the names of the variables the {{#if}} conditions of markdown
+// test.
+func conditionVariables(markdown string) map[string]bool {
+	names := make(map[string]bool)
+	for _, m := range conditionalRegex.FindAllStringSubmatch(markdown, -1) {
+		if m[1] != "" {
+			names[m[1]] = true
+		}
+	}
+	return names
+}
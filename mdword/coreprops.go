@@ -0,0 +1,30 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// titleKey is the data key whose value becomes the document title.
+const titleKey = "title"
+
+var titleElementRegex = regexp.MustCompile(`(?s)<dc:title\s*/>|<dc:title>.*?</dc:title>`)
+
+// setDocumentTitle sets the title core property, shown by Word in the title bar and the
+// recent files list.
+func (c *Converter) setDocumentTitle(pkg *docxPackage, title string) {
+	const coreName = "docProps/core.xml"
+	core, ok := pkg.parts[coreName]
+	if !ok {
+		c.Warnf("template has no core properties part, the document title is not set")
+		return
+	}
+	el := "<dc:title>" + xmlEscaper.Replace(strings.Join(strings.Fields(title), " ")) + "</dc:title>"
+	xml := string(core)
+	if titleElementRegex.MatchString(xml) {
+		xml = titleElementRegex.ReplaceAllLiteralString(xml, el)
+	} else {
+		xml = strings.Replace(xml, "</cp:coreProperties>", el+"</cp:coreProperties>", 1)
+	}
+	pkg.parts[coreName] = []byte(ensureNamespace(xml, "dc", "http://purl.org/dc/elements/1.1/"))
+}
@@ -0,0 +1,74 @@
+package mdword
+
+import "strings"
+
+// The data keys the -cover page is made of besides the title, typically given in the front
+// matter.
+const (
+	subtitleKey = "subtitle"
+	authorKey   = "author"
+	dateKey     = "date"
+	logoKey     = "logo"
+)
+
+// coverKeys are the keys used by the -cover page, which need no placeholder.
+var coverKeys = []string{titleKey, subtitleKey, authorKey, dateKey, logoKey}
+
+// The style IDs of the title and subtitle of the -cover page.
+const (
+	titleStyle    = "Title"
+	subtitleStyle = "Subtitle"
+)
+
+// coverBlocks returns the -cover page built from data: the logo image, the title and
+// subtitle in the Title and Subtitle styles, the author and the date, all centered and
+// followed by a page break. Missing keys are left out, and without a title there is no
+// cover page.
+func (r *renderer) coverBlocks(data map[string]string) []block {
+	title := strings.TrimSpace(data[titleKey])
+	if title == "" {
+		r.c.Warnf("-cover: no %s key, the cover page is left out", titleKey)
+		return nil
+	}
+	var blocks []block
+	if logo := strings.TrimSpace(data[logoKey]); logo != "" {
+		blocks = append(blocks, r.imageBlocks("", logo, "")...)
+	}
+	blocks = append(blocks, &paragraph{style: titleStyle, runs: []textRun{{text: title}}})
+	if subtitle := strings.TrimSpace(data[subtitleKey]); subtitle != "" {
+		blocks = append(blocks, &paragraph{style: subtitleStyle, runs: []textRun{{text: subtitle}}})
+	}
+	for _, key := range []string{authorKey, dateKey} {
+		if value := strings.TrimSpace(data[key]); value != "" {
+			blocks = append(blocks, &paragraph{style: r.c.opts.ParagraphStyle, runs: []textRun{{text: value}}})
+		}
+	}
+	for i, blk := range blocks {
+		blocks[i] = &centeredBlock{blk}
+	}
+	return append(blocks, &pageBreak{})
+}
+
+// centeredBlock writes a block with centered paragraphs.
+type centeredBlock struct {
+	block block
+}
+
+func (c *centeredBlock) writeXML(b *strings.Builder, ctx *blockContext) {
+	centeredCtx := *ctx
+	centeredCtx.pPr = setParagraphProps(ctx.pPr, `<w:jc w:val="center"/>`)
+	c.block.writeXML(b, &centeredCtx)
+}
+
+// insertCover places the sentinel of the -cover page in a paragraph of its own at the start
+// of the document body, for apply to swap it for the cover.
+func insertCover(pkg *docxPackage, sentinel string) {
+	const documentName = "word/document.xml"
+	xml := string(pkg.parts[documentName])
+	body := lastIndexTag(xml, "w:body")
+	if body < 0 {
+		return
+	}
+	body += strings.Index(xml[body:], ">") + 1
+	pkg.parts[documentName] = []byte(xml[:body] + "<w:p><w:r><w:t>" + sentinel + "</w:t></w:r></w:p>" + xml[body:])
+}
@@ -0,0 +1,59 @@
+package mdword
+
+import (
+	"os"
+	"regexp"
+)
+
+// tableDirectiveRegex matches a {{table: data.csv}} line, which is replaced by the content
+// of the CSV or TSV file as a table.
+var tableDirectiveRegex = regexp.MustCompile(`(?m)^\{\{\s*table:\s*(.+?)\s*\}\}$`)
+
+// csvTableBlock renders the data file referenced by a table directive, found with
+// resolvePath. A file which cannot be read, or any file with -safe, is reported and
+// replaced by a visible note.
+func (r *renderer) csvTableBlock(path string) block {
+	if r.c.opts.Safe {
+		r.c.Warnf("-safe: not reading table file %s", path)
+		return &paragraph{style: r.c.opts.ParagraphStyle, runs: []textRun{{text: "[table not included: " + path + "]"}}}
+	}
+	path = resolvePath(path, r.source)
+	content, err := os.ReadFile(path)
+	if err == nil {
+		var records [][]string
+		if records, err = readCSV(path, content); err == nil {
+			return recordsTable(records, r.c.opts.TableHeader, r.c.opts.TableAlign)
+		}
+	}
+	r.c.Warnf("unable to include table: %v", err)
+	return &paragraph{style: r.c.opts.ParagraphStyle, runs: []textRun{{text: "[missing table: " + path + "]"}}}
+}
+
+// recordsTable lays out CSV records as a table. The first record is the header row if
+// header is set and the columns are aligned according to align, see columnAlign.
+func recordsTable(records [][]string, header bool, align string) *table {
+	t := &table{}
+	for r, record := range records {
+		row := make([]tableCell, len(record))
+		for c, text := range record {
+			row[c] = tableCell{text: text, header: r == 0 && header, colspan: 1, rowspan: 1, align: columnAlign(align, c)}
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+// columnAlign returns the paragraph justification of the column according to the
+// -table-align letters, one per column of l, c or r.
+func columnAlign(align string, col int) string {
+	if col >= len(align) {
+		return ""
+	}
+	switch align[col] {
+	case 'c':
+		return "center"
+	case 'r':
+		return "right"
+	}
+	return ""
+}
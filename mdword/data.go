@@ -0,0 +1,96 @@
+package mdword
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// LoadDataJSON reads a flat JSON object of placeholder values. Numbers and booleans are
+// converted to their text form, numbers as written in the file.
+func LoadDataJSON(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]interface{}
+	if err := unmarshalNumbers(content, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON in %s: %w", path, err)
+	}
+
+	data := make(map[string]string, len(raw))
+	for key, value := range raw {
+		text, ok := jsonText(value)
+		if !ok {
+			return nil, fmt.Errorf("value of %q in %s must be a string, number or boolean", key, path)
+		}
+		data[key] = text
+	}
+	return data, nil
+}
+
+// jsonText returns the text form of a JSON string, number, boolean or null decoded by
+// unmarshalNumbers, null being empty. It reports false for arrays and objects.
+func jsonText(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nil:
+		return "", true
+	}
+	return "", false
+}
+
+// unmarshalNumbers decodes JSON like json.Unmarshal but keeps numbers as json.Number, so
+// that 1000000 stays 1000000 rather than becoming the float64 printed as 1e+06.
+func unmarshalNumbers(content []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after the top-level value")
+	}
+	return nil
+}
+
+// WriteDataJSON writes the placeholder data as an indented JSON object, which can be read
+// back with LoadDataJSON.
+func WriteDataJSON(path string, data map[string]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := EncodeDataJSON(f, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// EncodeDataJSON writes the placeholder data to w as an indented JSON object.
+func EncodeDataJSON(w io.Writer, data map[string]string) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// MergeData layers the given maps, later maps overriding keys of earlier ones.
+func MergeData(layers ...map[string]string) map[string]string {
+	data := make(map[string]string)
+	for _, layer := range layers {
+		for key, value := range layer {
+			data[key] = value
+		}
+	}
+	return data
+}
@@ -0,0 +1,133 @@
+package mdword
+
+import (
+	"sort"
+	"strings"
+)
+
+// settingsOrder lists the children of w:settings in schema order, up to the document
+// variables and the elements commonly following them.
+var settingsOrder = []string{
+	"w:writeProtection", "w:view", "w:zoom", "w:removePersonalInformation", "w:removeDateAndTime",
+	"w:doNotDisplayPageBoundaries", "w:displayBackgroundShape", "w:printPostScriptOverText",
+	"w:printFractionalCharacterWidth", "w:printFormsData", "w:embedTrueTypeFonts", "w:embedSystemFonts",
+	"w:saveSubsetFonts", "w:saveFormsData", "w:mirrorMargins", "w:alignBordersAndEdges",
+	"w:bordersDoNotSurroundHeader", "w:bordersDoNotSurroundFooter", "w:gutterAtTop",
+	"w:hideSpellingErrors", "w:hideGrammaticalErrors", "w:activeWritingStyle", "w:proofState",
+	"w:formsDesign", "w:attachedTemplate", "w:linkStyles", "w:stylePaneFormatFilter",
+	"w:stylePaneSortMethod", "w:documentType", "w:mailMerge", "w:revisionView", "w:trackRevisions",
+	"w:doNotTrackMoves", "w:doNotTrackFormatting", "w:documentProtection", "w:autoFormatOverride",
+	"w:styleLockTheme", "w:styleLockQFSet", "w:defaultTabStop", "w:autoHyphenation",
+	"w:consecutiveHyphenLimit", "w:hyphenationZone", "w:doNotHyphenateCaps", "w:showEnvelope",
+	"w:summaryLength", "w:clickAndTypeStyle", "w:defaultTableStyle", "w:evenAndOddHeaders",
+	"w:bookFoldRevPrinting", "w:bookFoldPrinting", "w:bookFoldPrintingSheets",
+	"w:drawingGridHorizontalSpacing", "w:drawingGridVerticalSpacing",
+	"w:displayHorizontalDrawingGridEvery", "w:displayVerticalDrawingGridEvery",
+	"w:doNotUseMarginsForDrawingGridOrigin", "w:drawingGridHorizontalOrigin",
+	"w:drawingGridVerticalOrigin", "w:doNotShadeFormData", "w:noPunctuationKerning",
+	"w:characterSpacingControl", "w:printTwoOnOne", "w:strictFirstAndLastChars",
+	"w:noLineBreaksAfter", "w:noLineBreaksBefore", "w:savePreviewPicture",
+	"w:doNotValidateAgainstSchema", "w:saveInvalidXml", "w:ignoreMixedContent",
+	"w:alwaysShowPlaceholderText", "w:doNotDemarcateInvalidXml", "w:saveXmlDataOnly",
+	"w:useXSLTWhenSaving", "w:saveThroughXslt", "w:showXMLTags", "w:alwaysMergeEmptyNamespace",
+	"w:updateFields", "w:hdrShapeDefaults", "w:footnotePr", "w:endnotePr", "w:compat", "w:docVars",
+	"w:rsids", "m:mathPr", "w:attachedSchema", "w:themeFontLang", "w:clrSchemeMapping",
+	"w:doNotIncludeSubdocsInStats", "w:doNotAutoCompressPictures", "w:forceUpgrade", "w:captions",
+	"w:readModeInkLockDown", "w:smartTagType", "sl:schemaLibrary", "w:shapeDefaults",
+	"w:doNotEmbedSmartTags", "w:decimalSymbol", "w:listSeparator",
+}
+
+const (
+	relTypeSettings     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/settings"
+	contentTypeSettings = "application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"
+)
+
+// documentVariables picks the data values stored as Word document variables: all of them,
+// or only the -docvar-keys if given.
+func (c *Converter) documentVariables(data map[string]string) map[string]string {
+	if !c.opts.DocVars && len(c.opts.DocVarKeys) == 0 {
+		return nil
+	}
+	if len(c.opts.DocVarKeys) == 0 {
+		return data
+	}
+	vars := make(map[string]string)
+	for _, key := range c.opts.DocVarKeys {
+		if value, ok := data[key]; ok {
+			vars[key] = value
+		} else {
+			c.Warnf("-docvar-keys: no value for %q", key)
+		}
+	}
+	return vars
+}
+
+// docVarEscaper escapes values for an XML attribute, keeping their line breaks.
+var docVarEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "\n", "&#xA;")
+
+// setDocumentVariables writes vars as document variables into the settings part, so that
+// DOCVARIABLE fields resolve when fields are updated. Existing variables of the same name
+// are replaced.
+func (c *Converter) setDocumentVariables(pkg *docxPackage, vars map[string]string) {
+	const settingsName = "word/settings.xml"
+	if _, ok := pkg.parts[settingsName]; !ok {
+		pkg.addPart(settingsName, []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+			`<w:settings xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"></w:settings>`), contentTypeSettings)
+		pkg.addRelationship(relTypeSettings, "settings.xml")
+	}
+	xml := string(pkg.parts[settingsName])
+	start := lastIndexTag(xml, "w:settings")
+	if start < 0 {
+		c.Warnf("unable to find the settings of the template, document variables are not set")
+		return
+	}
+	settings := xml[start:]
+	end := strings.LastIndex(settings, "</w:settings>")
+	if end < 0 {
+		c.Warnf("unable to find the settings of the template, document variables are not set")
+		return
+	}
+	settings = settings[:end+len("</w:settings>")]
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("<w:docVars>")
+	for _, el := range splitElements(innerElement(settings, "w:docVars")) {
+		if _, replaced := vars[attrValue(el, "w:name")]; !replaced {
+			b.WriteString(el)
+		}
+	}
+	for _, key := range keys {
+		b.WriteString(`<w:docVar w:name="` + docVarEscaper.Replace(key) + `" w:val="` + docVarEscaper.Replace(vars[key]) + `"/>`)
+	}
+	b.WriteString("</w:docVars>")
+
+	updated := setProps(settings, "w:settings", settingsOrder, []string{b.String()})
+	pkg.parts[settingsName] = []byte(xml[:start] + updated + xml[start+len(settings):])
+}
+
+// innerElement returns the content of the first element with the given name in s.
+func innerElement(s, name string) string {
+	i := strings.Index(s, "<"+name+">")
+	if i < 0 {
+		return ""
+	}
+	el := leadingElement(s[i:], name)
+	return strings.TrimSuffix(strings.TrimPrefix(el, "<"+name+">"), "</"+name+">")
+}
+
+// attrValue returns the raw value of an attribute of the element's start tag.
+func attrValue(el, attr string) string {
+	tag := el[:strings.Index(el, ">")+1]
+	_, rest, ok := strings.Cut(tag, " "+attr+`="`)
+	if !ok {
+		return ""
+	}
+	value, _, _ := strings.Cut(rest, `"`)
+	return value
+}
@@ -0,0 +1,206 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// emphasisRegex matches ***bold italic***, **bold**, *italic* and ~~strikethrough~~ spans,
+// and the same with underscores. The opening delimiter is followed and the closing one
+// preceded by a non-space character, so that "2 * 3 * 4" is left alone. Spans may contain
+// other spans, which are matched once the outer text is split off, but an italic span holds
+// no single delimiter of its own kind. The backslash escapes \*,
+// \_, \# and \\ are matched as well so that they are kept as literal characters and never
+// delimit a span.
+var emphasisRegex = regexp.MustCompile(`(?s)\\[\\*_#]|` +
+	`\*\*\*([^\s*](?:.*?[^\s\\])?)\*\*\*|\*\*([^\s*](?:.*?[^\s\\])?)\*\*|\*([^\s*](?:(?:[^*]|\*\*[^\s*][^*]*?\*\*)*?[^\s\\*])?)\*|` +
+	`~~([^\s~](?:.*?[^\s~])?)~~|` +
+	`___([^\s_](?:.*?[^\s\\])?)___|__([^\s_](?:.*?[^\s\\])?)__|_([^\s_](?:(?:[^_]|__[^\s_][^_]*?__)*?[^\s\\_])?)_`)
+
+// hasEmphasis reports whether text contains bold, italic or struck spans or backslash
+// escapes.
+func hasEmphasis(text string) bool {
+	if !strings.ContainsAny(text, "*_~\\") || !emphasisRegex.MatchString(text) {
+		return false
+	}
+	// underscores within words match but delimit nothing
+	runs := emphasize(textRun{text: text}, false, "", nil)
+	return len(runs) != 1 || runs[0] != textRun{text: text}
+}
+
+// unclosedMarkerRegex matches a delimiter which could open an emphasis span: at the start
+// of the text or after a space or punctuation, and followed by a non-space character.
+var unclosedMarkerRegex = regexp.MustCompile(`(?:^|[\s\p{P}\p{S}])(\*{1,3}|_{1,3}|~~)[^\s*_~]`)
+
+// emphasisRuns splits the plain text runs at bold, italic and struck spans, dropping the
+// delimiters and styling only the delimited text. With math set asterisks within $math$
+// spans are left alone. unbalanced is the -unbalanced-emphasis mode for markers which open
+// a span that is never closed, see emphasize, and warnf receives its warnings.
+func emphasisRuns(runs []textRun, math bool, unbalanced string, warnf func(format string, args ...interface{})) []textRun {
+	var result []textRun
+	for _, run := range runs {
+		if run.checkbox != noCheckbox || run.columnBreak || run.monospace {
+			result = append(result, run)
+			continue
+		}
+		result = append(result, emphasize(run, math, unbalanced, warnf)...)
+	}
+	return result
+}
+
+// emphasize splits a single run at its emphasis spans, styling each span and the spans
+// nested within it. Asterisks within -math spans are part of the math and left for
+// mathRuns, and underscores within words, as in snake_case_names, delimit nothing. A marker
+// opening a span which is never closed stays literal; with unbalanced set to autoclose it
+// styles the rest of the run instead, and with warn it is reported to warnf.
+func emphasize(run textRun, math bool, unbalanced string, warnf func(format string, args ...interface{})) []textRun {
+	var spans [][]int
+	if math {
+		spans = mathSpans(run.text)
+	}
+	matches := emphasisMatches(run.text, spans)
+	if unbalanced == "autoclose" || unbalanced == "warn" {
+		if p, marker := unclosedMarker(run.text, matches, spans); p >= 0 {
+			if unbalanced == "warn" {
+				warnf("emphasis marker %s is never closed in %q, keeping it as text", marker, run.text)
+			} else {
+				span := run.withText(run.text[p+len(marker):])
+				switch marker {
+				case "*", "_":
+					span.italic = true
+				case "**", "__":
+					span.bold = true
+				case "***", "___":
+					span.bold, span.italic = true, true
+				default:
+					span.strike = true
+				}
+				result := emphasize(run.withText(run.text[:p]), math, unbalanced, warnf)
+				if p == 0 {
+					// an empty run stands for the empty text
+					result = nil
+				}
+				return append(result, emphasize(span, math, unbalanced, warnf)...)
+			}
+		}
+	}
+
+	var result []textRun
+	last := 0
+	for _, m := range matches {
+		if m[0] > last {
+			result = append(result, run.withText(run.text[last:m[0]]))
+		}
+		switch {
+		case m[2] >= 0 || m[10] >= 0:
+			span := run.withText(submatch(run.text, m, 1, 5))
+			span.bold, span.italic = true, true
+			result = append(result, emphasize(span, math, unbalanced, warnf)...)
+		case m[4] >= 0 || m[12] >= 0:
+			span := run.withText(submatch(run.text, m, 2, 6))
+			span.bold = true
+			result = append(result, emphasize(span, math, unbalanced, warnf)...)
+		case m[6] >= 0 || m[14] >= 0:
+			span := run.withText(submatch(run.text, m, 3, 7))
+			span.italic = true
+			result = append(result, emphasize(span, math, unbalanced, warnf)...)
+		case m[8] >= 0:
+			span := run.withText(run.text[m[8]:m[9]])
+			span.strike = true
+			result = append(result, emphasize(span, math, unbalanced, warnf)...)
+		default:
+			// a backslash escape leaves the escaped character
+			result = append(result, run.withText(run.text[m[0]+1:m[1]]))
+		}
+		last = m[1]
+	}
+	if last < len(run.text) || last == 0 {
+		result = append(result, run.withText(run.text[last:]))
+	}
+	return result
+}
+
+// emphasisMatches returns the submatch indexes of the emphasis spans and escapes of text,
+// skipping those within the math spans and underscore spans within words.
+func emphasisMatches(text string, spans [][]int) [][]int {
+	var matches [][]int
+	pos := 0
+	for pos < len(text) {
+		m := emphasisRegex.FindStringSubmatchIndex(text[pos:])
+		if m == nil {
+			break
+		}
+		for i := range m {
+			if m[i] >= 0 {
+				m[i] += pos
+			}
+		}
+		if withinSpan(spans, m[0]) || withinSpan(spans, m[1]-1) || (m[10] >= 0 || m[12] >= 0 || m[14] >= 0) && intraword(text, m[0], m[1]) {
+			// look for a span starting after the rejected delimiter
+			pos = m[0] + 1
+			continue
+		}
+		matches = append(matches, m)
+		pos = m[1]
+	}
+	return matches
+}
+
+// unclosedMarker returns the offset of the first marker of text which opens a span but lies
+// outside of the matched spans and the math spans, and the marker itself, or -1.
+func unclosedMarker(text string, matches, spans [][]int) (int, string) {
+	last := 0
+	for i := 0; i <= len(matches); i++ {
+		end := len(text)
+		if i < len(matches) {
+			end = matches[i][0]
+		}
+		for _, m := range unclosedMarkerRegex.FindAllStringSubmatchIndex(text[last:end], -1) {
+			if p := last + m[2]; !withinSpan(spans, p) {
+				return p, text[p : last+m[3]]
+			}
+		}
+		if i < len(matches) {
+			last = matches[i][1]
+		}
+	}
+	return -1, ""
+}
+
+// hasUnclosedEmphasis reports whether text holds a marker opening a span which is never
+// closed.
+func hasUnclosedEmphasis(text string) bool {
+	if !strings.ContainsAny(text, "*_~") {
+		return false
+	}
+	p, _ := unclosedMarker(text, emphasisMatches(text, nil), nil)
+	return p >= 0
+}
+
+// submatch returns the text of whichever of the submatches asterisk and underscore matched.
+func submatch(text string, m []int, asterisk, underscore int) string {
+	if m[2*asterisk] >= 0 {
+		return text[m[2*asterisk]:m[2*asterisk+1]]
+	}
+	return text[m[2*underscore]:m[2*underscore+1]]
+}
+
+// intraword reports whether the span of text from start to end touches a letter or digit on
+// either side.
+func intraword(text string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	return unicode.IsLetter(before) || unicode.IsDigit(before) || unicode.IsLetter(after) || unicode.IsDigit(after)
+}
+
+// withinSpan reports whether the byte offset i lies within one of the submatch index spans.
+func withinSpan(spans [][]int, i int) bool {
+	for _, s := range spans {
+		if i >= s[0] && i < s[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package mdword
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// mojibakeRegex matches what a UTF-8 multi-byte sequence looks like after being decoded as
+// Windows-1252: a lead byte character followed by continuation byte characters, e.g. "Ã©"
+// for "é" or "â€™" for "’".
+var mojibakeRegex = regexp.MustCompile(`[\x{C2}-\x{F4}][\x{80}-\x{BF}€‚ƒ„…†‡ˆ‰Š‹ŒŽ‘’“”•–—˜™š›œžŸ]+`)
+
+// mojibake returns the first span of s which is UTF-8 text decoded as Windows-1252, and the
+// text it was meant to be.
+func mojibake(s string) (string, string, bool) {
+	for _, span := range mojibakeRegex.FindAllString(s, -1) {
+		b, err := charmap.Windows1252.NewEncoder().Bytes([]byte(span))
+		if err != nil || !utf8.Valid(b) {
+			continue
+		}
+		return span, string(b), true
+	}
+	return "", "", false
+}
+
+// checkEncoding warns about lines which are not valid UTF-8 or contain mojibake, both
+// signs that the markdown was saved or converted with the wrong encoding. Each problem is
+// reported once, naming the first line it occurs on and how many lines it affects.
+func (c *Converter) checkEncoding(lines []string) {
+	invalid, garbled := 0, 0
+	invalidLine, garbledLine := 0, 0
+	var span, meant string
+	for i, line := range lines {
+		if !utf8.ValidString(line) {
+			if invalid == 0 {
+				invalidLine = i + 1
+			}
+			invalid++
+			continue
+		}
+		if s, m, ok := mojibake(line); ok {
+			if garbled == 0 {
+				garbledLine, span, meant = i+1, s, m
+			}
+			garbled++
+		}
+	}
+	if invalid > 0 {
+		c.Warnf("line %d: the markdown is not valid UTF-8 (%d lines affected), save it as UTF-8 or give its encoding with -encoding, e.g. -encoding windows-1252, to avoid garbled text", invalidLine, invalid)
+	}
+	if garbled > 0 {
+		c.Warnf("line %d: %q looks like a garbled %q (%d lines affected), the UTF-8 markdown was probably decoded as Windows-1252 at some point, or read with the wrong -encoding", garbledLine, span, meant, garbled)
+	}
+}
+
+// decode returns the text of markdown content in the -encoding, UTF-8 if none is given.
+func (c *Converter) decode(content []byte) (string, error) {
+	if c.encoding == nil {
+		return string(content), nil
+	}
+	b, err := c.encoding.NewDecoder().Bytes(content)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode the markdown as %s: %w, check -encoding", c.opts.Encoding, err)
+	}
+	return string(b), nil
+}
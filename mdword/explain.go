@@ -0,0 +1,54 @@
+package mdword
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// explainf records a processing step of the document if key is the one traced with
+// -explain.
+func (d *Document) explainf(key, format string, args ...interface{}) {
+	if d.explainKey == "" || key != d.explainKey {
+		return
+	}
+	d.explanation = append(d.explanation, fmt.Sprintf(format, args...))
+}
+
+// Explain writes the steps of the parse of d which produced the value of key to w, followed
+// by how the data layers were merged into data. Options.ExplainKey must name the key when
+// parsing for the steps to be recorded.
+func (d *Document) Explain(w io.Writer, key string, defaults, sets, data map[string]string) {
+	var steps []string
+	if value, ok := defaults[key]; ok {
+		steps = append(steps, fmt.Sprintf("defaults: %q", value))
+	}
+	if key == d.explainKey {
+		steps = append(steps, d.explanation...)
+	}
+	if value, ok := d.Data[key]; ok {
+		steps = append(steps, fmt.Sprintf("markdown value: %q", value))
+	}
+	if value, ok := sets[key]; ok {
+		steps = append(steps, fmt.Sprintf("-set overrides the value: %q", value))
+	}
+
+	fmt.Fprintf(w, "Explaining %q\n", key)
+	value, ok := data[key]
+	if !ok {
+		fmt.Fprintln(w, "No source produces this key. Known keys:")
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s\n", k)
+		}
+		return
+	}
+	for i, step := range steps {
+		fmt.Fprintf(w, "%d. %s\n", i+1, step)
+	}
+	fmt.Fprintf(w, "Final value: %q\n", value)
+}
@@ -0,0 +1,66 @@
+package mdword
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var footerReferenceRegex = regexp.MustCompile(`<w:footerReference [^>]*/>`)
+
+// stampFooter adds a footer built from the -footer-format string to every section,
+// replacing the footers of the template. {date} is the generation date, {source} the
+// name of the source file and {page} the page number.
+func stampFooter(pkg *docxPackage, format, source string) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`)
+	b.WriteString(`<w:ftr xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:p>`)
+	text := strings.NewReplacer(
+		"{date}", time.Now().Format("2006-01-02"),
+		"{source}", filepath.Base(source),
+	).Replace(format)
+	for i, part := range strings.Split(text, "{page}") {
+		if i > 0 {
+			b.WriteString(`<w:fldSimple w:instr=" PAGE "><w:r><w:t>1</w:t></w:r></w:fldSimple>`)
+		}
+		if part != "" {
+			b.WriteString("<w:r>")
+			writeText(&b, part)
+			b.WriteString("</w:r>")
+		}
+	}
+	b.WriteString("</w:p></w:ftr>")
+
+	name := pkg.unusedPartName("word/footer", ".xml")
+	pkg.addPart(name, []byte(b.String()), contentTypeFooter)
+	id := pkg.addRelationship(relTypeFooter, strings.TrimPrefix(name, "word/"))
+
+	doc := string(pkg.parts["word/document.xml"])
+	doc = footerReferenceRegex.ReplaceAllString(doc, "")
+	doc = ensureNamespace(doc, "r", "http://schemas.openxmlformats.org/officeDocument/2006/relationships")
+	var refs string
+	for _, kind := range []string{"default", "first", "even"} {
+		refs += `<w:footerReference w:type="` + kind + `" r:id="` + id + `"/>`
+	}
+	if sectPrOpenRegex.MatchString(doc) {
+		doc = insertIntoSections(doc, refs)
+	} else {
+		// a body without section properties has a single default section
+		doc = strings.Replace(doc, "</w:body>", "<w:sectPr>"+refs+"</w:sectPr></w:body>", 1)
+	}
+	pkg.parts["word/document.xml"] = []byte(doc)
+}
+
+var sectPrOpenRegex = regexp.MustCompile(`<w:sectPr(\s[^>]*?)?(/?)>`)
+
+// insertIntoSections inserts content at the start of every section properties element.
+func insertIntoSections(doc, content string) string {
+	return sectPrOpenRegex.ReplaceAllStringFunc(doc, func(open string) string {
+		if strings.HasSuffix(open, "/>") {
+			return strings.TrimSuffix(open, "/>") + ">" + content + "</w:sectPr>"
+		}
+		return open + content
+	})
+}
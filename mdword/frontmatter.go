@@ -0,0 +1,72 @@
+package mdword
+
+import (
+	"strconv"
+	"strings"
+)
+
+// frontMatter reads a leading YAML front matter block fenced by --- lines and returns its
+// values with the number of lines it takes, 0 if there is none. Only flat "key: value" pairs
+// are understood; nested mappings and lists are skipped with a warning. Keys are kebab cased
+// like heading keys, and the values traced with -explain recorded in doc.
+func (c *Converter) frontMatter(doc *Document, lines []string) (map[string]string, int) {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return nil, 0
+	}
+	end := 0
+	for i := 1; i < len(lines); i++ {
+		if line := strings.TrimSpace(lines[i]); line == "---" || line == "..." {
+			end = i
+			break
+		}
+	}
+	if end == 0 {
+		return nil, 0
+	}
+
+	values := make(map[string]string)
+	nested := false
+	for i := 1; i < end; i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indented := strings.TrimLeft(line, " \t") != line || strings.HasPrefix(trimmed, "- ")
+		if nested && indented {
+			continue
+		}
+		nested = false
+		name, value, ok := strings.Cut(trimmed, ":")
+		if !ok || indented {
+			c.Warnf("line %d: ignoring front matter line %q, only key: value pairs are supported", i+1, trimmed)
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" && i+1 < end && strings.TrimLeft(lines[i+1], " \t-") != lines[i+1] {
+			c.Warnf("line %d: ignoring the nested front matter value of %q, only key: value pairs are supported", i+1, name)
+			nested = true
+			continue
+		}
+		key := c.kebabCase(c.sanitizeKey(name))
+		values[key] = frontMatterValue(value)
+		doc.explainf(key, "line %d: front matter gives the value %q", i+1, values[key])
+	}
+	return values, end + 1
+}
+
+// frontMatterValue unquotes a quoted scalar and drops a trailing comment from a plain one.
+func frontMatterValue(value string) string {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+	case strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) > 1:
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
@@ -0,0 +1,35 @@
+package mdword
+
+import (
+	"sort"
+	"strings"
+)
+
+// glossaryKey is the placeholder filled with a table of all definition list entries,
+// unless the data defines it explicitly.
+const glossaryKey = "glossary"
+
+type definition struct {
+	term string
+	text string
+}
+
+// glossaryTable renders the definitions as a two-column table sorted by term.
+func glossaryTable(defs []definition) *table {
+	sorted := append([]definition(nil), defs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].term) < strings.ToLower(sorted[j].term)
+	})
+
+	t := &table{rows: [][]tableCell{{
+		{text: "Term", header: true, colspan: 1, rowspan: 1},
+		{text: "Definition", header: true, colspan: 1, rowspan: 1},
+	}}}
+	for _, def := range sorted {
+		t.rows = append(t.rows, []tableCell{
+			{text: def.term, colspan: 1, rowspan: 1},
+			{text: def.text, colspan: 1, rowspan: 1},
+		})
+	}
+	return t
+}
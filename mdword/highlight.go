@@ -0,0 +1,65 @@
+package mdword
+
+import (
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// highlightStyle is the chroma style giving the colors of highlighted code.
+const highlightStyle = "friendly"
+
+// codeBlocks renders the lines of a fenced code block with -highlight as paragraphs of
+// monospaced runs, colored by token type if the language of the fence is known.
+func (r *renderer) codeBlocks(lang string, lines []string) []block {
+	codeStyle := r.c.opts.CodeStyle
+	code := strings.Join(lines, "\n")
+	var lexer chroma.Lexer
+	if lang != "" {
+		lexer = lexers.Get(lang)
+	}
+	if lexer == nil {
+		var blocks []block
+		for _, line := range lines {
+			blocks = append(blocks, &paragraph{style: codeStyle, runs: []textRun{{text: line, monospace: true}}})
+		}
+		return blocks
+	}
+
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		r.c.Warnf("unable to highlight %s code: %v", lang, err)
+		return r.codeBlocks("", lines)
+	}
+	style := styles.Get(highlightStyle)
+	current := &paragraph{style: codeStyle}
+	blocks := []block{current}
+	for _, token := range iterator.Tokens() {
+		entry := style.Get(token.Type)
+		for i, text := range strings.Split(token.Value, "\n") {
+			if i > 0 {
+				current = &paragraph{style: codeStyle}
+				blocks = append(blocks, current)
+			}
+			if text == "" {
+				continue
+			}
+			run := textRun{text: text, monospace: true, bold: entry.Bold == chroma.Yes, italic: entry.Italic == chroma.Yes}
+			if entry.Colour.IsSet() {
+				run.color = strings.ToUpper(strings.TrimPrefix(entry.Colour.String(), "#"))
+			}
+			if n := len(current.runs); n > 0 && current.runs[n-1].withText("") == run.withText("") {
+				current.runs[n-1].text += text
+				continue
+			}
+			current.runs = append(current.runs, run)
+		}
+	}
+	// the lexer ends the code with a newline of its own
+	if len(blocks) > len(lines) {
+		blocks = blocks[:len(lines)]
+	}
+	return blocks
+}
@@ -0,0 +1,251 @@
+package mdword
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var htmlTableRegex = regexp.MustCompile(`(?is)<table\b.*?</table\s*>`)
+
+type tableCell struct {
+	text    string
+	header  bool
+	colspan int
+	rowspan int
+	// align is the justification of the cell paragraph, empty for the default.
+	align string
+}
+
+type table struct {
+	rows [][]tableCell
+}
+
+// htmlTableBlocks splits value into its text and inline HTML tables.
+func (r *renderer) htmlTableBlocks(value string) []block {
+	var blocks []block
+	last := 0
+	for _, loc := range htmlTableRegex.FindAllStringIndex(value, -1) {
+		blocks = append(blocks, r.textBlocks(value[last:loc[0]])...)
+		blocks = append(blocks, parseHTMLTable(value[loc[0]:loc[1]], r.c.Warnf))
+		last = loc[1]
+	}
+	return append(blocks, r.textBlocks(value[last:])...)
+}
+
+// parseHTMLTable reads the rows and cells of a single HTML table. Only colspan and rowspan
+// are understood, any other attribute is ignored with a warning to warnf. As in HTML, a cell
+// left open ends at the next cell or row or the end of the table.
+func parseHTMLTable(s string, warnf func(format string, args ...interface{})) *table {
+	t := &table{}
+	z := html.NewTokenizer(strings.NewReader(s))
+	var cell *tableCell
+	warned := make(map[string]bool)
+	finish := func() {
+		if cell != nil {
+			row := len(t.rows) - 1
+			cell.text = strings.TrimSpace(cell.text)
+			t.rows[row] = append(t.rows[row], *cell)
+			cell = nil
+		}
+	}
+
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			finish()
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch tok.Data {
+			case "table", "thead", "tbody", "tfoot":
+				warnUnsupportedAttrs(tok, warned, warnf)
+			case "tr":
+				warnUnsupportedAttrs(tok, warned, warnf)
+				finish()
+				t.rows = append(t.rows, nil)
+			case "td", "th":
+				finish()
+				if len(t.rows) == 0 {
+					t.rows = append(t.rows, nil)
+				}
+				cell = &tableCell{header: tok.Data == "th", colspan: 1, rowspan: 1}
+				for _, attr := range tok.Attr {
+					switch attr.Key {
+					case "colspan":
+						cell.colspan = spanValue(attr.Key, attr.Val, maxColspan, warnf)
+					case "rowspan":
+						cell.rowspan = spanValue(attr.Key, attr.Val, maxRowspan, warnf)
+					default:
+						warnUnsupportedAttr(tok.Data, attr.Key, warned, warnf)
+					}
+				}
+			case "br":
+				if cell != nil {
+					cell.text += "\n"
+				}
+			}
+		case html.EndTagToken:
+			switch tok.Data {
+			case "td", "th", "tr", "table":
+				finish()
+			}
+		case html.TextToken:
+			if cell != nil {
+				cell.text += strings.Join(strings.Fields(tok.Data), " ")
+			}
+		}
+	}
+	return t
+}
+
+// The largest spans honored. Word tables have at most 63 columns, and HTML limits rowspan
+// to 65534; larger values in untrusted markdown would only blow up the grid.
+const (
+	maxColspan = 63
+	maxRowspan = 65534
+)
+
+// spanValue returns the value of a colspan or rowspan attribute, 1 if it is invalid and at
+// most max.
+func spanValue(attr, s string, max int, warnf func(format string, args ...interface{})) int {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || n < 1 {
+		return 1
+	}
+	if n > max {
+		warnf("%s %d is larger than %d, using %d", attr, n, max, max)
+		return max
+	}
+	return n
+}
+
+func warnUnsupportedAttrs(tok html.Token, warned map[string]bool, warnf func(format string, args ...interface{})) {
+	for _, attr := range tok.Attr {
+		warnUnsupportedAttr(tok.Data, attr.Key, warned, warnf)
+	}
+}
+
+func warnUnsupportedAttr(tag, attr string, warned map[string]bool, warnf func(format string, args ...interface{})) {
+	if warned[tag+" "+attr] {
+		return
+	}
+	warned[tag+" "+attr] = true
+	warnf("ignoring unsupported attribute %q on <%s>", attr, tag)
+}
+
+// gridCell is a position in the table grid, either the origin of a cell or a slot covered
+// by a cell spanning down from a previous row.
+type gridCell struct {
+	cell   *tableCell
+	merged bool
+}
+
+// grid lays out the cells honoring their spans and returns the grid with its column count.
+func (t *table) grid() ([][]gridCell, int) {
+	grid := make([][]gridCell, len(t.rows))
+	set := func(r, c int, g gridCell) {
+		for len(grid[r]) <= c {
+			grid[r] = append(grid[r], gridCell{})
+		}
+		grid[r][c] = g
+	}
+	cols := 0
+	for r, row := range t.rows {
+		c := 0
+		for i := range row {
+			cell := &row[i]
+			for c < len(grid[r]) && grid[r][c].cell != nil {
+				c++
+			}
+			set(r, c, gridCell{cell: cell})
+			// the rows below are covered across all the columns the cell spans
+			for rr := r + 1; rr < r+cell.rowspan && rr < len(t.rows); rr++ {
+				for cc := c; cc < c+cell.colspan; cc++ {
+					set(rr, cc, gridCell{cell: cell, merged: true})
+				}
+			}
+			c += cell.colspan
+			if c > cols {
+				cols = c
+			}
+		}
+	}
+	return grid, cols
+}
+
+// tableWidth is the total width in twips tables are spread across.
+const tableWidth = 9000
+
+func (t *table) writeXML(b *strings.Builder, ctx *blockContext) {
+	grid, cols := t.grid()
+	if cols == 0 {
+		return
+	}
+	colWidth := tableWidth / cols
+	if colWidth < 1 {
+		colWidth = 1
+	}
+
+	b.WriteString(`<w:tbl><w:tblPr><w:tblW w:w="0" w:type="auto"/><w:tblBorders>`)
+	for _, side := range []string{"top", "left", "bottom", "right", "insideH", "insideV"} {
+		b.WriteString(`<w:` + side + ` w:val="single" w:sz="4" w:space="0" w:color="auto"/>`)
+	}
+	b.WriteString(`</w:tblBorders></w:tblPr><w:tblGrid>`)
+	for i := 0; i < cols; i++ {
+		b.WriteString(`<w:gridCol w:w="` + strconv.Itoa(colWidth) + `"/>`)
+	}
+	b.WriteString(`</w:tblGrid>`)
+
+	for _, row := range grid {
+		b.WriteString("<w:tr>")
+		for c := 0; c < cols; {
+			var g gridCell
+			if c < len(row) {
+				g = row[c]
+			}
+			span := 1
+			if g.cell != nil {
+				span = g.cell.colspan
+			}
+			b.WriteString(`<w:tc><w:tcPr><w:tcW w:w="` + strconv.Itoa(colWidth*span) + `" w:type="dxa"/>`)
+			if span > 1 {
+				b.WriteString(`<w:gridSpan w:val="` + strconv.Itoa(span) + `"/>`)
+			}
+			switch {
+			case g.merged:
+				b.WriteString(`<w:vMerge/>`)
+			case g.cell != nil && g.cell.rowspan > 1:
+				b.WriteString(`<w:vMerge w:val="restart"/>`)
+			}
+			b.WriteString(`</w:tcPr>`)
+			b.WriteString("<w:p>")
+			if g.cell != nil && g.cell.align != "" {
+				b.WriteString(`<w:pPr><w:jc w:val="` + g.cell.align + `"/></w:pPr>`)
+			}
+			if g.cell != nil && !g.merged {
+				for i, line := range strings.Split(g.cell.text, "\n") {
+					b.WriteString("<w:r>")
+					if g.cell.header {
+						b.WriteString(setRunProps(ctx.rPr, "<w:b/>"))
+					} else {
+						b.WriteString(ctx.rPr)
+					}
+					if i > 0 {
+						b.WriteString("<w:br/>")
+					}
+					writeText(b, line)
+					b.WriteString("</w:r>")
+				}
+			}
+			b.WriteString("</w:p></w:tc>")
+			c += span
+		}
+		b.WriteString("</w:tr>")
+	}
+	b.WriteString("</w:tbl>")
+}
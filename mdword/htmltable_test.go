@@ -0,0 +1,117 @@
+package mdword
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// layout describes the grid of table as the text of the cell at each position, "^" where a
+// cell above spans down and "" where no cell is.
+func layout(t *table) [][]string {
+	grid, cols := t.grid()
+	rows := make([][]string, len(grid))
+	for r, row := range grid {
+		rows[r] = make([]string, cols)
+		for c, g := range row {
+			switch {
+			case g.merged:
+				rows[r][c] = "^"
+			case g.cell != nil:
+				rows[r][c] = g.cell.text
+			}
+		}
+	}
+	return rows
+}
+
+func TestParseHTMLTable(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want [][]string
+	}{
+		{
+			name: "plain",
+			html: "<table><tr><th>A</th><th>B</th></tr><tr><td>1</td><td> 2\n  two </td></tr></table>",
+			want: [][]string{{"A", "B"}, {"1", "2 two"}},
+		},
+		{
+			name: "colspan",
+			html: `<table><tr><td colspan="2">AB</td><td>C</td></tr><tr><td>1</td><td>2</td><td>3</td></tr></table>`,
+			want: [][]string{{"AB", "", "C"}, {"1", "2", "3"}},
+		},
+		{
+			name: "rowspan",
+			html: `<table><tr><td rowspan="2">A</td><td>B</td></tr><tr><td>C</td></tr></table>`,
+			want: [][]string{{"A", "B"}, {"^", "C"}},
+		},
+		{
+			name: "rowspan and colspan",
+			html: "<table><tr><td rowspan=2 colspan=2>A</td><td>B</td></tr><tr><td>Z</td></tr></table>",
+			want: [][]string{{"A", "", "B"}, {"^", "^", "Z"}},
+		},
+		{
+			name: "rowspan past the last row",
+			html: `<table><tr><td rowspan="5">A</td><td>B</td></tr></table>`,
+			want: [][]string{{"A", "B"}},
+		},
+		{
+			name: "unclosed cells and rows",
+			html: "<table><tr><td>a<td>b<tr><td>c</table>",
+			want: [][]string{{"a", "b"}, {"c", ""}},
+		},
+		{
+			name: "invalid spans",
+			html: `<table><tr><td colspan="x">a</td><td rowspan="-1">b</td></tr></table>`,
+			want: [][]string{{"a", "b"}},
+		},
+		{
+			name: "cell without row",
+			html: "<table><td>a</td></table>",
+			want: [][]string{{"a"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := layout(parseHTMLTable(tt.html, t.Logf)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLTableXML(t *testing.T) {
+	tbl := parseHTMLTable("<table><tr><td rowspan=2 colspan=2>A</td><td>B</td></tr><tr><td>Z</td></tr></table>", t.Logf)
+	var b strings.Builder
+	tbl.writeXML(&b, &blockContext{})
+	xml := b.String()
+	for _, want := range []string{
+		`<w:gridSpan w:val="2"/><w:vMerge w:val="restart"/>`,
+		`<w:gridSpan w:val="2"/><w:vMerge/>`,
+		`<w:t xml:space="preserve">Z</w:t>`,
+	} {
+		if !strings.Contains(xml, want) {
+			t.Errorf("table XML does not contain %s:\n%s", want, xml)
+		}
+	}
+	if rows := strings.Count(xml, "<w:tr>"); rows != 2 {
+		t.Errorf("table has %d rows, want 2", rows)
+	}
+}
+
+func TestHTMLTableHugeSpans(t *testing.T) {
+	tbl := parseHTMLTable(`<table><tr><td colspan="1000000000" rowspan="1000000000">A</td><td>B</td></tr><tr><td>C</td></tr></table>`, t.Logf)
+	grid, cols := tbl.grid()
+	if cols != maxColspan+1 {
+		t.Errorf("table has %d columns, want %d", cols, maxColspan+1)
+	}
+	if len(grid) != 2 || grid[1][maxColspan].cell == nil || grid[1][maxColspan].cell.text != "C" {
+		t.Errorf("C is not next to the merged cells: %q", layout(tbl))
+	}
+	var b strings.Builder
+	tbl.writeXML(&b, &blockContext{})
+	if n := strings.Count(b.String(), "<w:gridCol "); n != cols {
+		t.Errorf("table XML has %d grid columns, want %d", n, cols)
+	}
+}
@@ -0,0 +1,149 @@
+package mdword
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// imageLineRegex matches the lines holding just a markdown image, ![alt](path) with an
+// optional "title".
+var imageLineRegex = regexp.MustCompile(`(?m)^!\[([^\]\n]*)\]\((\S+?)(?:\s+"([^"\n]*)")?\)$`)
+
+const (
+	relTypeImage = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image"
+
+	wpNamespace  = "http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"
+	aNamespace   = "http://schemas.openxmlformats.org/drawingml/2006/main"
+	picNamespace = "http://schemas.openxmlformats.org/drawingml/2006/picture"
+
+	// emuPerPixel converts pixels at 96 dpi to the English Metric Units of DrawingML, and
+	// maxImageWidth is the width images are scaled down to, 6 inches.
+	emuPerPixel   = 9525
+	maxImageWidth = 6 * 914400
+
+	// captionStyle is the style ID of the caption below an image.
+	captionStyle = "Caption"
+)
+
+// imageContentTypes maps the formats image.DecodeConfig recognizes to their content type.
+var imageContentTypes = map[string]string{
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"gif":  "image/gif",
+}
+
+// picture is an image file embedded in the document.
+type picture struct {
+	name          string
+	content       []byte
+	format        string
+	width, height int
+}
+
+// imageBlocks renders the image file referenced by an image line, found with resolvePath,
+// followed by its title as a caption, or its alt text if it has none. A file which cannot
+// be read or is no PNG, JPEG or GIF image, or any file with -safe, is reported and replaced
+// by a visible note.
+func (r *renderer) imageBlocks(alt, file, title string) []block {
+	if r.c.opts.Safe {
+		r.c.Warnf("-safe: not reading image file %s", file)
+		return []block{&paragraph{style: r.c.opts.ParagraphStyle, runs: []textRun{{text: "[image not included: " + file + "]"}}}}
+	}
+	file = resolvePath(file, r.source)
+	content, err := os.ReadFile(file)
+	if err == nil {
+		var config image.Config
+		var format string
+		if config, format, err = image.DecodeConfig(bytes.NewReader(content)); err == nil {
+			pic := &picture{name: filepath.Base(file), content: content, format: format, width: config.Width, height: config.Height}
+			blocks := []block{&imageBlock{pic: pic, alt: alt}}
+			caption := title
+			if caption == "" {
+				caption = alt
+			}
+			if caption != "" {
+				blocks = append(blocks, &paragraph{style: captionStyle, runs: []textRun{{text: caption}}})
+			}
+			return blocks
+		}
+		err = fmt.Errorf("%s: %w, only PNG, JPEG and GIF images are supported", file, err)
+	}
+	r.c.Warnf("unable to include image: %v", err)
+	return []block{&paragraph{style: r.c.opts.ParagraphStyle, runs: []textRun{{text: "[missing image: " + file + "]"}}}}
+}
+
+// imageBlock is a paragraph holding an embedded picture.
+type imageBlock struct {
+	pic *picture
+	alt string
+}
+
+func (i *imageBlock) writeXML(b *strings.Builder, ctx *blockContext) {
+	id, n := ctx.rend.addImage(i.pic)
+	cx, cy := i.pic.width*emuPerPixel, i.pic.height*emuPerPixel
+	if cx > maxImageWidth {
+		cx, cy = maxImageWidth, cy*maxImageWidth/cx
+	}
+	extent := fmt.Sprintf(`cx="%d" cy="%d"`, cx, cy)
+	name := xmlEscaper.Replace(i.pic.name)
+	b.WriteString("<w:p>" + ctx.pPr + "<w:r><w:drawing>")
+	b.WriteString(`<wp:inline distT="0" distB="0" distL="0" distR="0"><wp:extent ` + extent + `/>`)
+	b.WriteString(`<wp:docPr id="` + strconv.Itoa(n) + `" name="` + name + `" descr="` + xmlEscaper.Replace(i.alt) + `"/>`)
+	b.WriteString(`<wp:cNvGraphicFramePr><a:graphicFrameLocks noChangeAspect="1"/></wp:cNvGraphicFramePr>`)
+	b.WriteString(`<a:graphic><a:graphicData uri="` + picNamespace + `"><pic:pic>`)
+	b.WriteString(`<pic:nvPicPr><pic:cNvPr id="0" name="` + name + `"/><pic:cNvPicPr/></pic:nvPicPr>`)
+	b.WriteString(`<pic:blipFill><a:blip r:embed="` + id + `"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`)
+	b.WriteString(`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext ` + extent + `/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`)
+	b.WriteString("</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r></w:p>")
+}
+
+// embeddedImage is a picture placed into a part, whose media part and relationship are
+// added once the part is rendered.
+type embeddedImage struct {
+	part, id string
+	pic      *picture
+}
+
+// addImage registers a picture placed into the part being rendered and returns its
+// relationship id with a document-unique drawing id. A picture placed more than once into
+// a part shares its relationship.
+func (r *renderer) addImage(pic *picture) (string, int) {
+	r.drawings++
+	n := imageDrawingBase + r.drawings
+	for _, img := range r.images {
+		if img.part == r.part && img.pic == pic {
+			return img.id, n
+		}
+	}
+	id := "rIdMdwordImage" + strconv.Itoa(len(r.images)+1)
+	r.images = append(r.images, embeddedImage{part: r.part, id: id, pic: pic})
+	return id, n
+}
+
+// imageDrawingBase is the first drawing id of embedded images, well above the ids Word
+// gives the drawings of a template.
+const imageDrawingBase = 100000
+
+// addImages adds the media parts of the images written by rend and their relationships.
+func addImages(pkg *docxPackage, rend *renderer) {
+	media := make(map[*picture]string)
+	for _, img := range rend.images {
+		name, ok := media[img.pic]
+		if !ok {
+			name = pkg.unusedPartName("word/media/mdwordImage", "."+img.pic.format)
+			pkg.addPart(name, img.pic.content, imageContentTypes[img.pic.format])
+			media[img.pic] = name
+		}
+		// content parts and media both live in word/
+		pkg.addRelationshipFrom(img.part, img.id, relTypeImage, strings.TrimPrefix(name, "word/"), false)
+	}
+}
@@ -0,0 +1,79 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// imageDefinitionRegex matches the definition of a reference, [ref]: path with an optional
+// "title", the path possibly in <angle brackets>.
+var imageDefinitionRegex = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:[ \t]*(?:<([^>\n]*)>|(\S+))(?:[ \t]+"([^"\n]*)")?[ \t]*$`)
+
+// imageReferenceRegex matches reference-style images, ![alt][ref], or ![alt][] using the alt
+// text as the reference.
+var imageReferenceRegex = regexp.MustCompile(`!\[([^\]\n]*)\]\[([^\]\n]*)\]`)
+
+// imageTarget is the path and title of a reference definition.
+type imageTarget struct {
+	path, title string
+}
+
+// imageReferences resolves the reference-style images of lines from line skip on, rewriting
+// them as inline images, ![alt](path "title"), so that they are embedded like those. The
+// definition lines become blank so line numbers stay the same. An image whose reference is
+// not defined is reported and leaves its alt text. Code is left alone.
+func (c *Converter) imageReferences(lines []string, skip int) {
+	targets := make(map[string]imageTarget)
+	inCode := false
+	for i := skip; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+			inCode = !inCode
+			continue
+		}
+		m := imageDefinitionRegex.FindStringSubmatch(lines[i])
+		if inCode || m == nil {
+			continue
+		}
+		label := referenceLabel(m[1])
+		if _, defined := targets[label]; !defined {
+			// the first definition of a label wins
+			targets[label] = imageTarget{path: m[2] + m[3], title: m[4]}
+		}
+		lines[i] = ""
+	}
+
+	inCode = false
+	for i := skip; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode || !strings.Contains(lines[i], "![") {
+			continue
+		}
+		n := i + 1
+		lines[i] = imageReferenceRegex.ReplaceAllStringFunc(lines[i], func(image string) string {
+			m := imageReferenceRegex.FindStringSubmatch(image)
+			alt, ref := m[1], m[2]
+			if ref == "" {
+				ref = alt
+			}
+			target, ok := targets[referenceLabel(ref)]
+			if !ok {
+				c.Warnf("line %d: image reference [%s] is not defined, keeping the alt text %q", n, ref, alt)
+				return alt
+			}
+			inline := "![" + alt + "](" + strings.ReplaceAll(target.path, " ", "%20")
+			if target.title != "" {
+				inline += ` "` + target.title + `"`
+			}
+			return inline + ")"
+		})
+	}
+}
+
+// referenceLabel normalizes a reference label: labels match regardless of case and
+// whitespace.
+func referenceLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
@@ -0,0 +1,31 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// listLineRegex matches list item lines, capturing their indentation.
+var listLineRegex = regexp.MustCompile(`^([ \t]*)(?:[-+*]|\d+[.)])\s`)
+
+// checkListIndentation warns about list blocks indented with both tabs and spaces, which
+// nest inconsistently depending on the tab width. Each block is reported once, naming the
+// first line which mixes the two.
+func (c *Converter) checkListIndentation(lines []string) {
+	tabs, spaces, warned := false, false, false
+	for i, line := range lines {
+		m := listLineRegex.FindStringSubmatch(line)
+		if m == nil {
+			if strings.TrimSpace(line) == "" || !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+				tabs, spaces, warned = false, false, false
+			}
+			continue
+		}
+		tabs = tabs || strings.Contains(m[1], "\t")
+		spaces = spaces || strings.Contains(m[1], " ")
+		if tabs && spaces && !warned {
+			c.Warnf("line %d: list indentation mixes tabs and spaces", i+1)
+			warned = true
+		}
+	}
+}
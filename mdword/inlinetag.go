@@ -0,0 +1,91 @@
+package mdword
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// inlineTagRegex matches simple inline HTML tags without attributes, e.g. <br>, <br/>,
+// <b> and </b>, in any case.
+var inlineTagRegex = regexp.MustCompile(`(?i)<(/?)([a-z][a-z0-9]*)\s*/?>`)
+
+// inlineTagStyles maps the inline tags turned into run formatting to the formatting they set.
+var inlineTagStyles = map[string]string{
+	"b":      "bold",
+	"strong": "bold",
+	"i":      "italic",
+	"em":     "italic",
+	"s":      "strike",
+	"del":    "strike",
+	"strike": "strike",
+	"sub":    "subscript",
+	"sup":    "superscript",
+	"code":   "code",
+}
+
+// hasInlineTags reports whether text contains inline HTML tags.
+func hasInlineTags(text string) bool {
+	return strings.Contains(text, "<") && inlineTagRegex.MatchString(text)
+}
+
+// inlineTagRuns turns the inline HTML tags of a paragraph's runs into formatting: <br> into
+// a line break and <b>, <i>, <s>, <sub>, <sup>, <code> and their synonyms into the style of
+// the text up to their closing tag or the end of the paragraph. Closing tags which close
+// nothing are dropped, unknown tags are kept as text.
+func inlineTagRuns(runs []textRun, logger *log.Logger) []textRun {
+	var result []textRun
+	open := make(map[string]int)
+	styled := func(run textRun, text string) textRun {
+		run.text = text
+		run.bold = run.bold || open["bold"] > 0
+		run.italic = run.italic || open["italic"] > 0
+		run.strike = run.strike || open["strike"] > 0
+		run.monospace = run.monospace || open["code"] > 0
+		if open["subscript"] > 0 {
+			run.vertAlign = "subscript"
+		} else if open["superscript"] > 0 {
+			run.vertAlign = "superscript"
+		}
+		return run
+	}
+	for _, run := range runs {
+		if run.checkbox != noCheckbox || run.columnBreak || run.monospace || run.text == "" {
+			result = append(result, run)
+			continue
+		}
+		var text strings.Builder
+		flush := func() {
+			if text.Len() > 0 {
+				result = append(result, styled(run, text.String()))
+				text.Reset()
+			}
+		}
+		last := 0
+		for _, m := range inlineTagRegex.FindAllStringSubmatchIndex(run.text, -1) {
+			text.WriteString(run.text[last:m[0]])
+			last = m[1]
+			closing, name := m[3] > m[2], strings.ToLower(run.text[m[4]:m[5]])
+			style, known := inlineTagStyles[name]
+			switch {
+			case name == "br":
+				// newlines within a run are written as line breaks
+				text.WriteString("\n")
+			case !known:
+				logger.Printf("leaving unknown tag %s as text", run.text[m[0]:m[1]])
+				text.WriteString(run.text[m[0]:m[1]])
+			case closing:
+				flush()
+				if open[style] > 0 {
+					open[style]--
+				}
+			default:
+				flush()
+				open[style]++
+			}
+		}
+		text.WriteString(run.text[last:])
+		flush()
+	}
+	return result
+}
@@ -0,0 +1,66 @@
+package mdword
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// LoadKeyMap reads a mapping of data keys to the template placeholders they fill. A .json
+// file holds an object of placeholder names by key; any other file has a "key = placeholder"
+// line per key, blank lines and lines starting with # being ignored.
+func LoadKeyMap(path string) (map[string]string, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return LoadDataJSON(path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keyMap := make(map[string]string)
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, placeholder, ok := strings.Cut(line, "=")
+		key, placeholder = strings.TrimSpace(key), strings.TrimSpace(placeholder)
+		if !ok || key == "" || placeholder == "" {
+			return nil, fmt.Errorf("%s:%d: expected key = placeholder, got %q", path, i+1, line)
+		}
+		keyMap[key] = placeholder
+	}
+	return keyMap, nil
+}
+
+// mapKeys returns data with its keys renamed according to -keymap. Keys which are not
+// mapped are kept as they are.
+func (c *Converter) mapKeys(data map[string]string) map[string]string {
+	keyMap := c.opts.KeyMap
+	if len(keyMap) == 0 {
+		return data
+	}
+	mapped := make(map[string]string, len(data))
+	for key, value := range data {
+		if placeholder, ok := keyMap[key]; ok {
+			key = placeholder
+		}
+		mapped[key] = value
+	}
+	return mapped
+}
+
+// logKeyMap reports the -keymap entries in verbose mode.
+func (c *Converter) logKeyMap() {
+	keyMap := c.opts.KeyMap
+	keys := make([]string, 0, len(keyMap))
+	for key := range keyMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		c.logger.Printf("key map: %s -> %s", key, keyMap[key])
+	}
+}
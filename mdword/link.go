@@ -0,0 +1,94 @@
+package mdword
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// linkRegex matches markdown links, [label](url) with an optional "title", and bare or
+// <angle bracketed> http(s) URLs, which are linked as well.
+var linkRegex = regexp.MustCompile(`\[([^\]\n]+)\]\((\S+?)(?:\s+"[^"\n]*")?\)|<(https?://[^\s<>]+)>|(https?://[^\s<>()\[\]]+)`)
+
+const (
+	relTypeHyperlink = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/hyperlink"
+	relNamespace     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+	// linkColor is the text color of hyperlinks, for templates without a Hyperlink style.
+	linkColor = "0563C1"
+)
+
+// hasLinks reports whether text contains markdown links or URLs.
+func hasLinks(text string) bool {
+	return (strings.Contains(text, "](") || strings.Contains(text, "://")) && linkRegex.MatchString(text)
+}
+
+// linkRuns splits the plain text runs at links. Markdown links leave their label, URLs
+// themselves; both link to the URL.
+func linkRuns(runs []textRun) []textRun {
+	var result []textRun
+	for _, run := range runs {
+		if run.checkbox != noCheckbox || run.columnBreak || run.monospace || run.link != "" {
+			result = append(result, run)
+			continue
+		}
+		last := 0
+		for _, m := range linkRegex.FindAllStringSubmatchIndex(run.text, -1) {
+			if m[2] >= 0 && m[0] > 0 && run.text[m[0]-1] == '!' {
+				// images within text are not embedded and kept as they are
+				continue
+			}
+			end := m[1]
+			var span textRun
+			switch {
+			case m[2] >= 0:
+				span = run.withText(run.text[m[2]:m[3]])
+				span.link = run.text[m[4]:m[5]]
+			case m[6] >= 0:
+				span = run.withText(run.text[m[6]:m[7]])
+				span.link = span.text
+			default:
+				// punctuation ending a sentence is not part of a bare URL
+				url := strings.TrimRight(run.text[m[8]:m[9]], ".,;:!?'\"")
+				end = m[8] + len(url)
+				span = run.withText(url)
+				span.link = url
+			}
+			if m[0] > last {
+				result = append(result, run.withText(run.text[last:m[0]]))
+			}
+			result = append(result, span)
+			last = end
+		}
+		if last < len(run.text) || last == 0 {
+			result = append(result, run.withText(run.text[last:]))
+		}
+	}
+	return result
+}
+
+// hyperlink is a link written into a part, whose relationship is added once the part is
+// rendered.
+type hyperlink struct {
+	part, id, url string
+}
+
+// addLink registers a link from the part being rendered and returns its relationship id.
+// Links to the same URL share a relationship.
+func (r *renderer) addLink(url string) string {
+	for _, link := range r.links {
+		if link.part == r.part && link.url == url {
+			return link.id
+		}
+	}
+	id := "rIdMdwordLink" + strconv.Itoa(len(r.links)+1)
+	r.links = append(r.links, hyperlink{part: r.part, id: id, url: url})
+	return id
+}
+
+// addLinks adds the relationships of the links written by rend to their parts.
+func addLinks(pkg *docxPackage, rend *renderer) {
+	for _, link := range rend.links {
+		pkg.addRelationshipFrom(link.part, link.id, relTypeHyperlink, link.url, true)
+	}
+}
@@ -0,0 +1,64 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mathRegex matches inline $math$ spans following the Pandoc rules: the opening $ is followed
+// and the closing $ preceded by a non-space character. Escaped \$ are matched as well so
+// that they are kept as literal dollar signs.
+var mathRegex = regexp.MustCompile(`\\\$|\$([^\s$](?:[^$\n]*[^\s$\\])?)\$`)
+
+// mathSpans returns the submatch indexes of the math spans and escaped dollars in text. A
+// closing $ directly followed by a digit does not end a span, like in "$5 to $10".
+func mathSpans(text string) [][]int {
+	var spans [][]int
+	for _, m := range mathRegex.FindAllStringSubmatchIndex(text, -1) {
+		if m[2] >= 0 && m[1] < len(text) && text[m[1]] >= '0' && text[m[1]] <= '9' {
+			continue
+		}
+		spans = append(spans, m)
+	}
+	return spans
+}
+
+// hasMath reports whether text contains inline math or escaped dollars to be handled.
+func hasMath(text string) bool {
+	return strings.Contains(text, "$") && len(mathSpans(text)) > 0
+}
+
+// mathRuns splits the plain text runs at math spans, dropping the $ delimiters and styling
+// the math according to mode, the -math option. Escaped dollars become literal ones.
+func mathRuns(runs []textRun, mode string) []textRun {
+	var result []textRun
+	for _, run := range runs {
+		if run.checkbox != noCheckbox || run.columnBreak || run.monospace {
+			result = append(result, run)
+			continue
+		}
+		last := 0
+		for _, m := range mathSpans(run.text) {
+			if m[0] > last {
+				result = append(result, run.withText(run.text[last:m[0]]))
+			}
+			if m[2] < 0 {
+				result = append(result, run.withText("$"))
+			} else {
+				span := run.withText(run.text[m[2]:m[3]])
+				switch mode {
+				case "italic":
+					span.italic = true
+				case "mono":
+					span.monospace = true
+				}
+				result = append(result, span)
+			}
+			last = m[1]
+		}
+		if last < len(run.text) || last == 0 {
+			result = append(result, run.withText(run.text[last:]))
+		}
+	}
+	return result
+}
@@ -0,0 +1,1036 @@
+// Package mdword fills the placeholders of Word templates with values taken from markdown.
+//
+// A Converter holds the options of the conversion, which the markdowntoword command takes
+// from its flags. Parsing markdown gives a Document, the values of the placeholders along
+// with the definitions and headings the glossary and table of contents are made of, which
+// is then rendered into a template.
+package mdword
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/lukasjarosch/go-docx"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// Options configure the conversion. The fields correspond to the flags of the same name of
+// the markdowntoword command.
+type Options struct {
+	Verbose                bool
+	AllowHTMLTables        bool
+	AllowColor             bool
+	RTL                    bool
+	Math                   string
+	UnbalancedEmphasis     string
+	Compression            string
+	TableHeader            bool
+	TableAlign             string
+	KeepTrailingBlank      bool
+	Renumber               bool
+	Bullet                 string
+	OpenDelim              string
+	CloseDelim             string
+	KeyStyle               string
+	KeyIncludeLevel        bool
+	PreserveCase           bool
+	OrdinalScope           string
+	StripTags              []string
+	ParagraphStyle         string
+	ListStyle              string
+	CodeStyle              string
+	QuoteStyle             string
+	HorizontalRule         string
+	SmartyPants            bool
+	Highlight              bool
+	StripLinePrefix        string
+	Columns                int
+	Strict                 bool
+	Safe                   bool
+	DocLang                string
+	FooterFormat           string
+	DocVars                bool
+	DocVarKeys             []string
+	InteractiveCheckboxes  bool
+	CommentsAsWordComments bool
+	RequireReplacement     bool
+	AllowEmpty             bool
+	ExplainKey             string
+	TOC                    bool
+	Cover                  bool
+	Encoding               string
+
+	// KeyMap renames data keys to the template placeholders they fill, see LoadKeyMap.
+	KeyMap map[string]string
+	// Vars are the variables {{#if}} conditions are evaluated against.
+	Vars map[string]string
+	// Log receives the warnings and errors of the conversions and the debug messages of
+	// Verbose ones; nil discards them.
+	Log io.Writer
+	// DocumentHook is called with the go-docx document of every render, after the plain
+	// text values replaced their placeholders and before the document is written, and may
+	// modify it, e.g. with SetFile. Values rendered as rich content, like styled text, tables or
+	// images, are still placeholders of their own then; they are filled in the written
+	// archive, together with links, comments, the language, footer stamp, document
+	// variables and title, after the hook. An error of the hook fails the render. Hooks
+	// run one at a time and must not render with a Converter themselves.
+	DocumentHook func(*docx.Document) error
+}
+
+// DefaultOptions returns the options converting like the markdowntoword command without
+// any flags.
+func DefaultOptions() Options {
+	return Options{
+		TableHeader:    true,
+		KeyStyle:       "text",
+		OrdinalScope:   "global",
+		Columns:        2,
+		Bullet:         "•",
+		HorizontalRule: "drop",
+		OpenDelim:      "{",
+		CloseDelim:     "}",
+		Log:            os.Stderr,
+	}
+}
+
+// A Converter parses markdown and renders templates as its options say. It keeps no state
+// between conversions but their Stats and may be used concurrently.
+type Converter struct {
+	opts Options
+
+	// open and close delimit the placeholders of templates.
+	open, close rune
+
+	// taskListRegex matches values holding task list items and taskItemRegex the list items
+	// of a task list, e.g. "• [x] done". Both follow the -bullet marker.
+	taskListRegex, taskItemRegex *regexp.Regexp
+
+	// encoding decodes markdown in the -encoding, it is nil for UTF-8.
+	encoding encoding.Encoding
+
+	// logger receives the debug messages of -v, it discards them otherwise. A Logger
+	// serializes its writes, so concurrent conversions do not interleave their lines.
+	logger *log.Logger
+	// diagnostics receives the warnings and errors, on the Options.Log.
+	diagnostics *log.Logger
+
+	// statsMu guards stats, the totals of the conversions done.
+	statsMu sync.Mutex
+	stats   Counts
+}
+
+// NewConverter validates the options and returns a Converter using them.
+func NewConverter(o Options) (*Converter, error) {
+	if o.KeyStyle != "text" && o.KeyStyle != "ordinal" {
+		return nil, fmt.Errorf("-key-style must be text or ordinal")
+	}
+	if o.OrdinalScope != "global" && o.OrdinalScope != "level" {
+		return nil, fmt.Errorf("-ordinal-scope must be global or level")
+	}
+	if o.Compression != "" && o.Compression != "store" && o.Compression != "fast" && o.Compression != "best" {
+		return nil, fmt.Errorf("-compression must be store, fast or best")
+	}
+	if o.Math != "" && o.Math != "strip" && o.Math != "italic" && o.Math != "mono" {
+		return nil, fmt.Errorf("-math must be strip, italic or mono")
+	}
+	if o.UnbalancedEmphasis != "" && o.UnbalancedEmphasis != "literal" && o.UnbalancedEmphasis != "autoclose" && o.UnbalancedEmphasis != "warn" {
+		return nil, fmt.Errorf("-unbalanced-emphasis must be literal, autoclose or warn")
+	}
+	if o.HorizontalRule != "drop" && o.HorizontalRule != "page" {
+		return nil, fmt.Errorf("-horizontal-rule must be drop or page")
+	}
+	if o.Bullet == "" {
+		return nil, fmt.Errorf("-bullet must not be empty")
+	}
+	openDelim, err := delimiter("-open-delim", o.OpenDelim)
+	if err != nil {
+		return nil, err
+	}
+	closeDelim, err := delimiter("-close-delim", o.CloseDelim)
+	if err != nil {
+		return nil, err
+	}
+	if openDelim == closeDelim {
+		return nil, fmt.Errorf("-open-delim and -close-delim must differ")
+	}
+
+	c := &Converter{opts: o, open: openDelim, close: closeDelim}
+	if o.Encoding != "" {
+		enc, err := htmlindex.Get(o.Encoding)
+		if err != nil {
+			return nil, fmt.Errorf("-encoding: unknown encoding %q, e.g. windows-1252, iso-8859-15 or utf-16le", o.Encoding)
+		}
+		// UTF-8 needs no decoding, invalid bytes are reported by checkEncoding instead
+		if name, _ := htmlindex.Name(enc); name != "utf-8" {
+			c.encoding = enc
+		}
+	}
+	c.taskListRegex, c.taskItemRegex = taskRegexes(o.Bullet)
+	c.logger = log.New(io.Discard, "", 0)
+	c.diagnostics = log.New(io.Discard, "", 0)
+	if o.Log != nil {
+		c.diagnostics = log.New(o.Log, "", 0)
+	}
+	if o.Verbose && o.Log != nil {
+		c.logger = log.New(o.Log, "debug: ", 0)
+	}
+	c.logKeyMap()
+	return c, nil
+}
+
+// Document is the result of parsing markdown.
+type Document struct {
+	// Data holds the placeholder values by key.
+	Data map[string]string
+	// Source names the input of the document, for the -stamp-footer and to resolve the
+	// paths of images and table files.
+	Source string
+
+	// definitions holds the definition list entries for the glossary and headings the
+	// headings for the -toc.
+	definitions []definition
+	headings    []tocEntry
+
+	// conditions holds the variables tested by {{#if}} conditions, which count as used
+	// keys under -strict.
+	conditions map[string]bool
+
+	// explainKey is the key traced with -explain and explanation its processing steps.
+	explainKey  string
+	explanation []string
+}
+
+// Counts are totals of the conversions done by a Converter: the documents generated, the
+// placeholders filled and left empty, the data keys and words of filled content they were
+// rendered from and the diagnostics reported.
+type Counts struct {
+	Documents    int
+	Placeholders int
+	Unfilled     int
+	Keys         int
+	Words        int
+	Warnings     int
+	Errors       int
+}
+
+// Stats returns the totals of the conversions done so far.
+func (c *Converter) Stats() Counts {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.stats
+}
+
+// count adds n to one of the stats.
+func (c *Converter) count(total *int, n int) {
+	c.statsMu.Lock()
+	*total += n
+	c.statsMu.Unlock()
+}
+
+// Warnf reports a warning to the Options.Log and counts it.
+func (c *Converter) Warnf(format string, args ...interface{}) {
+	c.count(&c.stats.Warnings, 1)
+	c.diagnostics.Printf("Warning: "+format, args...)
+}
+
+// Errorf reports an error to the Options.Log and counts it.
+func (c *Converter) Errorf(format string, args ...interface{}) {
+	c.count(&c.stats.Errors, 1)
+	c.diagnostics.Printf("Error: "+format, args...)
+}
+
+func (c *Converter) sanitizeKey(s string) string {
+	// Use Unicode-aware case folding
+	if !c.opts.PreserveCase {
+		s = cases.Fold().String(s)
+	}
+
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsSpace(r) || r == '_' || r == '-' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// kebabCase joins the words of a sanitized key with single dashes. Runs of whitespace,
+// underscores and dashes collapse into one dash and leading or trailing ones are dropped.
+// The key is lower cased unless -preserve-case is set.
+func (c *Converter) kebabCase(s string) string {
+	if !c.opts.PreserveCase {
+		s = strings.ToLower(s)
+	}
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_' || r == '-'
+	}), "-")
+}
+
+// ParseMarkdown reads markdown and returns the placeholder values it defines with the
+// default options, see Converter.ParseMarkdown.
+func ParseMarkdown(r io.Reader) (map[string]string, error) {
+	c, err := NewConverter(DefaultOptions())
+	if err != nil {
+		return nil, err
+	}
+	doc, err := c.ParseMarkdown(r)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Data, nil
+}
+
+// ParseMarkdown reads markdown and returns the document it defines. Its data holds the
+// placeholder values keyed by the kebab case text of their third-level heading or
+// definition list term, prefixed by the preceding second-level heading.
+func (c *Converter) ParseMarkdown(r io.Reader) (*Document, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	markdown, err := c.decode(content)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := c.parseDocument(markdown)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkKeys(doc.Data); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// ParseMarkdownFile reads the markdown file at path, see ParseMarkdown. The document's
+// source is path.
+func (c *Converter) ParseMarkdownFile(path string) (*Document, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	markdown, err := c.decode(content)
+	var doc *Document
+	if err == nil {
+		doc, err = c.parseDocument(markdown)
+	}
+	if err == nil {
+		err = c.checkKeys(doc.Data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	doc.Source = path
+	return doc, nil
+}
+
+// ParseMarkdownFiles reads the markdown files at paths in order and merges their values,
+// keys of later files overriding those of earlier ones. The path - reads stdin. The glossary
+// holds the definition lists of all files, the source is the first file, and verbose mode
+// reports the file each key came from.
+func (c *Converter) ParseMarkdownFiles(paths []string) (*Document, error) {
+	merged := &Document{Data: make(map[string]string), conditions: make(map[string]bool), explainKey: c.opts.ExplainKey}
+	origins := make(map[string]string)
+	for _, path := range paths {
+		var content []byte
+		var err error
+		if path == "-" {
+			path = "stdin"
+			content, err = io.ReadAll(os.Stdin)
+		} else {
+			content, err = os.ReadFile(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		markdown, err := c.decode(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		doc, err := c.parseDocument(markdown)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for key, value := range doc.Data {
+			merged.Data[key] = value
+			origins[key] = path
+		}
+		if merged.Source == "" {
+			merged.Source = path
+		}
+		merged.definitions = append(merged.definitions, doc.definitions...)
+		merged.headings = append(merged.headings, doc.headings...)
+		merged.explanation = append(merged.explanation, doc.explanation...)
+		for name := range doc.conditions {
+			merged.conditions[name] = true
+		}
+	}
+	if err := c.checkKeys(merged.Data); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(origins))
+	for key := range origins {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		c.logger.Printf("%s from %s", key, origins[key])
+	}
+	return merged, nil
+}
+
+// parseDocument parses markdown into a document without a source. Keys given more than
+// once and conditional tags which do not pair up are reported, and fail the parse under
+// -strict.
+func (c *Converter) parseDocument(markdown string) (*Document, error) {
+	doc := &Document{explainKey: c.opts.ExplainKey}
+	var defs []definition
+	var toc []tocEntry
+	// Windows line endings would leave a carriage return on every line
+	markdown = strings.ReplaceAll(markdown, "\r\n", "\n")
+	if strings.Contains(markdown, "{{") {
+		var problems []string
+		doc.conditions = conditionVariables(markdown)
+		markdown, problems = applyConditionals(markdown, c.opts.Vars)
+		for _, problem := range problems {
+			c.Warnf("%s", problem)
+		}
+		if c.opts.Strict && len(problems) > 0 {
+			return nil, fmt.Errorf("conditional blocks do not pair up: %s", strings.Join(problems, ", "))
+		}
+	}
+	if len(c.opts.StripTags) > 0 {
+		stripped := c.stripTags(markdown, c.opts.StripTags)
+		if stripped != markdown {
+			doc.explainf(doc.explainKey, "-strip-tags removed %s elements before parsing", strings.Join(c.opts.StripTags, ", "))
+		}
+		markdown = stripped
+	}
+	lines := strings.Split(strings.TrimSuffix(markdown, "\n"), "\n")
+	c.checkListIndentation(lines)
+	c.checkEncoding(lines)
+
+	// front matter values come first, keys of the body override them
+	data, skip := c.frontMatter(doc, lines)
+	if data == nil {
+		data = make(map[string]string)
+	}
+	setextHeadings(lines, skip)
+	c.imageReferences(lines, skip)
+	origins := keyOrigins{origins: make(map[string]string), warnf: c.Warnf}
+	currentPrefix := ""
+	currentKey := ""
+	currentValue := ""
+	previousLine := ""
+	// defKey is the key of the definition indented lines continue
+	defKey := ""
+	sectionCount := 0
+	headingCount := 0
+	// levelKeys holds the key of the last heading of each level from 3 down, which deeper
+	// headings are nested under, and subCounts numbers the headings below level 3 for
+	// -key-style=ordinal.
+	var levelKeys [7]string
+	var subCounts [7]int
+
+	for i, line := range lines {
+		if i < skip {
+			continue
+		}
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+		line = strings.TrimSpace(line)
+
+		level := headingLevel(line)
+		continued := defKey != "" && indented && line != "" && level == 0 && !strings.HasPrefix(line, ":")
+		if !continued {
+			defKey = ""
+		}
+		if continued {
+			// An indented line continues the definition above it on a line of its own
+			value := collapseSpace(c.stripPrefix(line))
+			doc.explainf(defKey, "line %d: indented line continues the definition: %q", i+1, value)
+			data[defKey] += "\n" + value
+			defs[len(defs)-1].text = data[defKey]
+		} else if level >= 3 {
+			// Third-level and deeper headings
+			c.logger.Printf("found heading: %s", line)
+			heading := strings.TrimPrefix(line, strings.Repeat("#", level))
+			part := c.sanitizeKey(heading)
+			c.logger.Printf("sanitized key: %s", part)
+			part = c.kebabCase(part)
+			c.logger.Printf("key to kebab case: %s", part)
+			if c.opts.KeyIncludeLevel {
+				part = fmt.Sprintf("h%d-%s", level, part)
+			}
+			key := part
+			if c.opts.KeyStyle == "ordinal" && level == 3 {
+				headingCount++
+				key = c.ordinalKey(currentPrefix, headingCount)
+			} else {
+				if c.opts.KeyStyle == "ordinal" {
+					subCounts[level]++
+					key = strconv.Itoa(subCounts[level])
+				}
+				if parent := parentKey(levelKeys, level, currentPrefix); parent != "" {
+					key = parent + "-" + key
+				}
+			}
+			levelKeys[level] = key
+			for l := level + 1; l < len(levelKeys); l++ {
+				levelKeys[l], subCounts[l] = "", 0
+			}
+			toc = append(toc, tocEntry{level: level, title: headingTitle(line, level)})
+			doc.explainf(key, "line %d: heading %q gives the key", i+1, line)
+			origins.claim(key, fmt.Sprintf("heading %q on line %d", line, i+1))
+
+			if currentKey != "" {
+				data[currentKey] = c.finishValue(doc, currentKey, currentValue)
+			}
+
+			currentKey = key
+			currentValue = ""
+		} else if strings.HasPrefix(line, ":") && headingLevel(previousLine) > 0 {
+			// A definition right under a heading has no term of its own, it is part of the
+			// heading's value instead of a key colliding with the heading's.
+			value := c.stripPrefix(strings.TrimSpace(strings.TrimPrefix(line, ":")))
+			if currentKey != "" {
+				doc.explainf(currentKey, "line %d: definition without a term is added to the value", i+1)
+				currentValue += value + "\n"
+			} else {
+				c.Warnf("line %d: ignoring definition without a term after %q", i+1, previousLine)
+			}
+		} else if strings.HasPrefix(line, ":") {
+			// Definition list item
+			parts := strings.SplitN(line, ":", 2)
+			if len(parts) == 2 {
+				key := c.kebabCase(c.sanitizeKey(previousLine))
+				value := collapseSpace(c.stripPrefix(strings.TrimSpace(parts[1])))
+				if currentPrefix != "" {
+					key = currentPrefix + "-" + key
+				}
+				doc.explainf(key, "line %d: definition of %q gives the key", i+1, previousLine)
+				origins.claim(key, fmt.Sprintf("definition of %q on line %d", previousLine, i+1))
+				doc.explainf(key, "definition value: %q", value)
+				data[key] = value
+				defs = append(defs, definition{term: previousLine, text: value})
+				defKey = key
+			}
+		} else if term, value, ok := compactDefinition(line); ok && currentKey == "" {
+			// Definition on one line, "Term : value". Within a heading's value such lines
+			// are prose and kept.
+			key := c.kebabCase(c.sanitizeKey(term))
+			if currentPrefix != "" {
+				key = currentPrefix + "-" + key
+			}
+			value = collapseSpace(c.stripPrefix(value))
+			doc.explainf(key, "line %d: definition of %q gives the key", i+1, term)
+			origins.claim(key, fmt.Sprintf("definition of %q on line %d", term, i+1))
+			doc.explainf(key, "definition value: %q", value)
+			data[key] = value
+			defs = append(defs, definition{term: term, text: value})
+			defKey = key
+		} else if level == 1 {
+			// A title heading ends the value and the section before it
+			if currentKey != "" {
+				data[currentKey] = c.finishValue(doc, currentKey, currentValue)
+			}
+			currentKey = ""
+			currentValue = ""
+			currentPrefix = ""
+			levelKeys, subCounts = [7]string{}, [7]int{}
+		} else if level == 2 {
+			// Second-level heading
+			if currentKey != "" {
+				data[currentKey] = c.finishValue(doc, currentKey, currentValue)
+			}
+			currentKey = ""
+			currentValue = ""
+			levelKeys, subCounts = [7]string{}, [7]int{}
+			toc = append(toc, tocEntry{level: 2, title: headingTitle(line, 2)})
+
+			currentPrefix = c.kebabCase(c.sanitizeKey(strings.TrimPrefix(line, "##")))
+			if c.opts.KeyIncludeLevel {
+				currentPrefix = "h2-" + currentPrefix
+			}
+			if c.opts.KeyStyle == "ordinal" {
+				sectionCount++
+				currentPrefix = fmt.Sprintf("section-%d", sectionCount)
+				if c.opts.OrdinalScope == "level" {
+					headingCount = 0
+				}
+			}
+		} else if currentKey != "" {
+			// Append line to current value
+			stripped := c.stripPrefix(line)
+			if stripped != line {
+				doc.explainf(currentKey, "line %d: -strip-line-prefix turns %q into %q", i+1, line, stripped)
+			}
+			currentValue += stripped + "\n"
+		}
+
+		previousLine = line
+	}
+
+	// Handle the last heading or definition list item
+	if currentKey != "" {
+		data[currentKey] = c.finishValue(doc, currentKey, currentValue)
+	}
+	if c.opts.Verbose {
+		c.logger.Printf("data length is %d", len(data))
+		for key, value := range data {
+			c.logger.Printf("%s: %q", key, value)
+		}
+	}
+	if c.opts.Strict && len(origins.overwritten) > 0 {
+		return nil, fmt.Errorf("keys given more than once: %s", strings.Join(origins.overwritten, ", "))
+	}
+
+	doc.Data, doc.definitions, doc.headings = data, defs, toc
+	return doc, nil
+}
+
+// checkKeys fails the parse of markdown which gave no keys, unless -allow-empty is set. That
+// is almost always markdown whose headings are not at the levels keys are taken from.
+func (c *Converter) checkKeys(data map[string]string) error {
+	if len(data) > 0 || c.opts.AllowEmpty {
+		return nil
+	}
+	return fmt.Errorf("no keys found in the markdown; check the heading levels, keys come from ### headings and definition lists while ## headings only prefix them (-allow-empty accepts markdown without keys)")
+}
+
+// keyOrigins records where each parsed key comes from, to report keys whose values
+// overwrite each other.
+type keyOrigins struct {
+	origins     map[string]string
+	overwritten []string
+	warnf       func(format string, args ...interface{})
+}
+
+// claim records that origin gives key and warns if an earlier heading or definition gave
+// it already. Front matter values are meant to be overridden and are not claimed.
+func (o *keyOrigins) claim(key, origin string) {
+	if previous, ok := o.origins[key]; ok {
+		o.warnf("%s gives the key %q of the %s, whose value it overwrites", origin, key, previous)
+		o.overwritten = append(o.overwritten, key)
+	}
+	o.origins[key] = origin
+}
+
+// compactDefinition splits a one line definition, "Term : value", at the first " : ". The
+// value may contain colons of its own, as in times and URLs.
+func compactDefinition(line string) (term, value string, ok bool) {
+	term, value, ok = strings.Cut(line, " : ")
+	term, value = strings.TrimSpace(term), strings.TrimSpace(value)
+	return term, value, ok && term != "" && headingLevel(line) == 0
+}
+
+// collapseSpace collapses the runs of spaces and tabs of a definition value line, which
+// come from the formatting of the source, to single spaces.
+func collapseSpace(line string) string {
+	return strings.Join(strings.FieldsFunc(line, func(r rune) bool {
+		return r == ' ' || r == '\t'
+	}), " ")
+}
+
+// headingLevel returns the level of an ATX heading line, 0 if line is no heading. A single
+// # must be followed by a space, so that #tags in values are kept as text.
+func headingLevel(line string) int {
+	n := len(line) - len(strings.TrimLeft(line, "#"))
+	if n == 0 || n > 6 || n == 1 && !strings.HasPrefix(line, "# ") {
+		return 0
+	}
+	return n
+}
+
+// parentKey returns the key a heading of the given level is nested under: the key of the
+// closest shallower heading of level 3 or deeper, or else the section prefix.
+func parentKey(levelKeys [7]string, level int, prefix string) string {
+	for l := level - 1; l >= 3; l-- {
+		if levelKeys[l] != "" {
+			return levelKeys[l]
+		}
+	}
+	return prefix
+}
+
+// stripPrefix removes the -strip-line-prefix from a value line. The rest is trimmed like
+// any other line, so quoted list items become list items. A line consisting of just the
+// prefix without its trailing spaces, like an empty quoted line, becomes empty.
+func (c *Converter) stripPrefix(line string) string {
+	if c.opts.StripLinePrefix == "" {
+		return line
+	}
+	if line == strings.TrimRight(c.opts.StripLinePrefix, " \t") {
+		return ""
+	}
+	if rest, ok := strings.CutPrefix(line, c.opts.StripLinePrefix); ok {
+		return strings.TrimSpace(rest)
+	}
+	return line
+}
+
+// ordinalKey returns the key of the n-th third-level heading for -key-style=ordinal. With the
+// level scope headings are numbered within their second-level section.
+func (c *Converter) ordinalKey(prefix string, n int) string {
+	if c.opts.OrdinalScope == "level" && prefix != "" {
+		return fmt.Sprintf("%s-%d", prefix, n)
+	}
+	return fmt.Sprintf("section-%d", n)
+}
+
+// RenderTemplate fills the placeholders of the template with data using the default
+// options and writes the resulting document to w, see Converter.Render.
+func RenderTemplate(templatePath string, data map[string]string, w io.Writer) error {
+	c, err := NewConverter(DefaultOptions())
+	if err != nil {
+		return err
+	}
+	return c.Render(&Document{Data: data}, templatePath, w)
+}
+
+// Render fills the placeholders of the template with the values of doc and writes the
+// resulting document to w.
+func (c *Converter) Render(doc *Document, templatePath string, w io.Writer) error {
+	pkg, err := c.render(doc, templatePath)
+	if err != nil {
+		return err
+	}
+	return pkg.write(w, c.opts.Compression)
+}
+
+// RenderFile fills the placeholders of the template with the values of doc and writes the
+// resulting document to outputPath, creating its directory if needed.
+func (c *Converter) RenderFile(doc *Document, templatePath, outputPath string) error {
+	pkg, err := c.render(doc, templatePath)
+	if err != nil {
+		return err
+	}
+	if err := pkg.writeFile(outputPath, c.opts.Compression); err != nil {
+		return fmt.Errorf("unable to write %s: %w", outputPath, err)
+	}
+	return nil
+}
+
+// ConvertFile parses the markdown file at markdownPath and renders the template with its
+// values, layered over defaults and under overrides, to outputPath.
+func (c *Converter) ConvertFile(markdownPath, templatePath, outputPath string, defaults, overrides map[string]string) error {
+	content, err := os.ReadFile(markdownPath)
+	if err != nil {
+		return err
+	}
+	markdown, err := c.decode(content)
+	if err != nil {
+		return err
+	}
+	doc, err := c.parseDocument(markdown)
+	if err != nil {
+		return err
+	}
+	if err := c.checkKeys(doc.Data); err != nil {
+		return err
+	}
+	doc.Source = markdownPath
+	doc.Data = MergeData(defaults, doc.Data, overrides)
+	return c.RenderFile(doc, templatePath, outputPath)
+}
+
+// docxMu serializes the calls into go-docx, which numbers the runs and fragments of the
+// documents it opens with unguarded package counters and reads the placeholder delimiters
+// from package variables. Only opening, listing, replacing and writing hold it; rendering
+// the values and post-processing the written package run concurrently.
+var docxMu sync.Mutex
+
+// useDelimiters makes go-docx find the placeholders between the delimiters of c. Its
+// delimiter regexes are compiled once at start up and have to be replaced along with the
+// runes. docxMu must be held.
+func (c *Converter) useDelimiters() {
+	if docx.OpenDelimiter == c.open && docx.CloseDelimiter == c.close {
+		return
+	}
+	docx.ChangeOpenCloseDelimiter(c.open, c.close)
+	docx.OpenDelimiterRegex = regexp.MustCompile(regexp.QuoteMeta(string(c.open)))
+	docx.CloseDelimiterRegex = regexp.MustCompile(regexp.QuoteMeta(string(c.close)))
+}
+
+// render fills the template with the values of d. The definition list entries of d fill
+// the glossary, its headings the -toc, and images and table files are looked up next to
+// its source.
+func (c *Converter) render(d *Document, templateFile string) (*docxPackage, error) {
+	doc, placeholders, err := c.openTemplate(templateFile)
+	if err != nil {
+		return nil, err
+	}
+	data := c.mapKeys(d.Data)
+	keys := len(data)
+	data = addTruncatedValues(data, placeholders)
+
+	rend := &renderer{c: c, source: d.Source, vars: c.documentVariables(data), title: data[titleKey], lang: c.opts.DocLang}
+	if rend.lang == "" {
+		rend.lang = data[langKey]
+	}
+	rend.rtl = c.opts.RTL || strings.EqualFold(data[dirKey], "rtl")
+	replaceMap := docx.PlaceholderMap{}
+	for key, value := range data {
+		if rend.needsRendering(value) {
+			replaceMap[key] = rend.placeholder(value)
+			continue
+		}
+		if c.opts.SmartyPants {
+			value = smartPunctuation(value, 0)
+		}
+		replaceMap[key] = braceUnescaper.Replace(value)
+	}
+	if _, ok := data[glossaryKey]; !ok && len(d.definitions) > 0 {
+		replaceMap[glossaryKey] = rend.add([]block{glossaryTable(d.definitions)})
+	}
+	if _, ok := data[tocKey]; !ok && c.opts.TOC {
+		if !slices.Contains(placeholders, tocKey) {
+			c.Warnf("-toc: %s has no %s placeholder", templateFile, c.Placeholder(tocKey))
+		}
+		if len(d.headings) > 0 {
+			replaceMap[tocKey] = rend.add(rend.tocBlocks(d.headings))
+		}
+	}
+	var used []string
+	if c.opts.Cover {
+		if blocks := rend.coverBlocks(data); blocks != nil {
+			rend.cover = rend.add(blocks)
+		}
+		used = coverKeys
+	}
+	c.count(&c.stats.Keys, keys)
+	c.count(&c.stats.Words, filledWords(placeholders, data))
+	if !replacesAny(placeholders, replaceMap) {
+		c.Warnf("none of the placeholders of %s match the data, the output equals the template", templateFile)
+		if c.opts.Strict || c.opts.RequireReplacement {
+			return nil, fmt.Errorf("no placeholder of %s was replaced", templateFile)
+		}
+	} else if unfilled, unused := unmatchedKeys(placeholders, data, replaceMap, rend.vars, d.conditions, used); len(unfilled) > 0 || len(unused) > 0 {
+		if len(unfilled) > 0 {
+			c.Warnf("placeholders of %s without a value: %s", templateFile, strings.Join(unfilled, ", "))
+		}
+		if len(unused) > 0 {
+			c.Warnf("keys matching no placeholder of %s: %s", templateFile, strings.Join(unused, ", "))
+		}
+		if c.opts.Strict {
+			return nil, fmt.Errorf("the placeholders of %s and the data do not match", templateFile)
+		}
+	}
+	return fillDocument(doc, placeholders, replaceMap, rend)
+}
+
+// replacesAny reports whether at least one of the template placeholders has a value.
+func replacesAny(placeholders []string, replaceMap docx.PlaceholderMap) bool {
+	for _, key := range placeholders {
+		if _, ok := replaceMap[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// openTemplate opens the template file and lists the keys of its placeholders.
+func (c *Converter) openTemplate(templateFile string) (*docx.Document, []string, error) {
+	docxMu.Lock()
+	defer docxMu.Unlock()
+	c.useDelimiters()
+
+	c.logger.Printf("looking for placeholders to replace in %s", templateFile)
+	doc, err := docx.Open(templateFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open template %s: %w", templateFile, err)
+	}
+	placeholders, err := templatePlaceholders(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, placeholders, nil
+}
+
+// delimiter returns the placeholder delimiter given to flag. go-docx matches single
+// characters only, and the characters escaped in document XML never match.
+func delimiter(flag, value string) (rune, error) {
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("%s must be a single character", flag)
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	if strings.ContainsRune("<>&", r) {
+		return 0, fmt.Errorf("%s cannot be %q, it is escaped in document XML", flag, value)
+	}
+	return r, nil
+}
+
+// Placeholder returns the placeholder of key as written in a template with the default
+// delimiters.
+func Placeholder(key string) string {
+	return "{" + key + "}"
+}
+
+// Placeholder returns the placeholder of key as written in a template, between the
+// delimiters of c.
+func (c *Converter) Placeholder(key string) string {
+	return string(c.open) + key + string(c.close)
+}
+
+// templatePlaceholders returns the keys of all placeholders in the template. docxMu must
+// be held.
+func templatePlaceholders(doc *docx.Document) ([]string, error) {
+	placeholders, err := doc.GetPlaceHoldersList()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list template placeholders: %w", err)
+	}
+	keys := make([]string, len(placeholders))
+	for i, placeholder := range placeholders {
+		keys[i] = docx.RemovePlaceholderDelimiter(placeholder)
+	}
+	return keys, nil
+}
+
+// TemplatePlaceholders returns the keys of all placeholders in the template file.
+func (c *Converter) TemplatePlaceholders(templateFile string) ([]string, error) {
+	_, placeholders, err := c.openTemplate(templateFile)
+	return placeholders, err
+}
+
+// fillDocument fills the template holding placeholders with replaceMap and returns the
+// resulting package, with any blocks registered with rend swapped in.
+func fillDocument(doc *docx.Document, placeholders []string, replaceMap docx.PlaceholderMap, rend *renderer) (*docxPackage, error) {
+	c := rend.c
+	replaced := 0
+	for _, key := range placeholders {
+		if _, ok := replaceMap[key]; ok {
+			replaced++
+		}
+	}
+	c.count(&c.stats.Placeholders, replaced)
+	c.count(&c.stats.Unfilled, len(placeholders)-replaced)
+
+	buf, err := c.replaceAll(doc, replaceMap)
+	if err != nil {
+		return nil, err
+	}
+	pkg, err := readPackage(buf)
+	if err != nil {
+		return nil, err
+	}
+	rend.commentBase = firstCommentID(pkg)
+	if rend.cover != "" {
+		insertCover(pkg, rend.cover)
+	}
+	for _, name := range pkg.contentParts() {
+		rend.part = name
+		pkg.parts[name] = rend.apply(pkg.parts[name])
+	}
+	addLinks(pkg, rend)
+	addImages(pkg, rend)
+	if len(rend.comments) > 0 {
+		addComments(pkg, rend)
+	}
+	if rend.lang != "" {
+		c.setDocumentLanguage(pkg, rend.lang)
+	}
+	if c.opts.FooterFormat != "" {
+		stampFooter(pkg, c.opts.FooterFormat, rend.source)
+	}
+	if len(rend.vars) > 0 {
+		c.setDocumentVariables(pkg, rend.vars)
+	}
+	if rend.title != "" {
+		c.setDocumentTitle(pkg, rend.title)
+	}
+	c.count(&c.stats.Documents, 1)
+	return pkg, nil
+}
+
+// replaceAll replaces the placeholders of doc with the values of replaceMap and returns the
+// document written.
+func (c *Converter) replaceAll(doc *docx.Document, replaceMap docx.PlaceholderMap) ([]byte, error) {
+	docxMu.Lock()
+	defer docxMu.Unlock()
+	c.useDelimiters()
+
+	if err := doc.ReplaceAll(replaceMap); err != nil {
+		return nil, fmt.Errorf("unable to replace placeholders: %w", err)
+	}
+	c.logger.Printf("replacements completed successfully")
+	if c.opts.DocumentHook != nil {
+		if err := c.opts.DocumentHook(doc); err != nil {
+			return nil, fmt.Errorf("document hook: %w", err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		return nil, fmt.Errorf("unable to write document: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// finishValue processes an accumulated heading value and trims the surrounding whitespace.
+// With -keep-trailing-blank a trailing blank line is kept as a single newline.
+func (c *Converter) finishValue(doc *Document, key, value string) string {
+	doc.explainf(key, "collected lines: %q", value)
+	processed := processValue(value, c.opts.Bullet, c.opts.Renumber)
+	if processed != value {
+		doc.explainf(key, "list markers become bullets: %q", processed)
+	}
+	trimmed := strings.TrimSpace(processed)
+	if c.opts.KeepTrailingBlank && trimmed != "" && strings.HasSuffix(strings.TrimRight(value, " \t"), "\n\n") {
+		doc.explainf(key, "-keep-trailing-blank keeps the trailing blank line")
+		return trimmed + "\n"
+	}
+	if trimmed != processed {
+		doc.explainf(key, "surrounding whitespace trimmed: %q", trimmed)
+	}
+	return trimmed
+}
+
+// orderedItemRegex matches the items of an ordered list, e.g. "1. first" or "2) second".
+var orderedItemRegex = regexp.MustCompile(`^(\d+)[.)]\s+`)
+
+// processValue marks the items of bullet lists with bullet and gives ordered list items a
+// uniform marker, renumbering them from 1 if renumber is set. Fenced code is left alone.
+func processValue(value, bullet string, renumber bool) string {
+	listItems := strings.Split(value, "\n")
+	var bulletPoints []string
+	number := 0
+	inCode := false
+	for _, item := range listItems {
+		// lines of fenced code blocks are kept verbatim
+		if strings.HasPrefix(item, "```") {
+			inCode = !inCode
+		}
+		// horizontal rules are no list items, textBlocks drops them or breaks the page
+		if inCode || strings.HasPrefix(item, "```") || thematicBreakRegex.MatchString(item) {
+			bulletPoints = append(bulletPoints, item)
+			number = 0
+			continue
+		}
+		if m := orderedItemRegex.FindStringSubmatch(item); m != nil {
+			// ordered items get a uniform "N. " marker, renumbered from 1 with -renumber
+			number++
+			if !renumber {
+				number, _ = strconv.Atoi(m[1])
+			}
+			item = strconv.Itoa(number) + ". " + item[len(m[0]):]
+		} else {
+			number = 0
+		}
+		if (strings.HasPrefix(item, "-") || strings.HasPrefix(item, "+")) && !isTableSeparator(item) {
+			item = bullet + item[1:]
+		}
+		bulletPoints = append(bulletPoints, item)
+	}
+	return strings.Join(bulletPoints, "\n")
+}
@@ -0,0 +1,92 @@
+package mdword
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LoadMergeRows reads the records of a mail merge data file. JSON files must hold an array
+// of flat objects, any other file is read as CSV with the column headers as keys (TSV for
+// the .tsv extension).
+func LoadMergeRows(path string) ([]map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var raw []map[string]interface{}
+		if err := unmarshalNumbers(content, &raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON in %s: %w", path, err)
+		}
+		rows := make([]map[string]string, len(raw))
+		for i, record := range raw {
+			rows[i] = make(map[string]string, len(record))
+			for key, value := range record {
+				if value == nil {
+					continue
+				}
+				text, ok := jsonText(value)
+				if !ok {
+					return nil, fmt.Errorf("value of %q in row %d of %s must be a string, number or boolean", key, i+1, path)
+				}
+				rows[i][key] = text
+			}
+		}
+		return rows, nil
+	}
+
+	records, err := readCSV(path, content)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, key := range header {
+			row[strings.TrimSpace(key)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// readCSV reads the records of a CSV file, or a TSV file for the .tsv extension.
+func readCSV(path string, content []byte) ([][]string, error) {
+	r := csv.NewReader(strings.NewReader(string(content)))
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		r.Comma = '\t'
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV in %s: %w", path, err)
+	}
+	return records, nil
+}
+
+var outputPatternRegex = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// MergeOutputPath expands an -output-pattern for the n-th row. {n} is the row number
+// starting at 1, any other {column} is the value of that column.
+func MergeOutputPath(pattern string, n int, row map[string]string) string {
+	return outputPatternRegex.ReplaceAllStringFunc(pattern, func(token string) string {
+		name := token[1 : len(token)-1]
+		if name == "n" {
+			return strconv.Itoa(n)
+		}
+		value, ok := row[name]
+		if !ok {
+			return token
+		}
+		return strings.NewReplacer("/", "-", "\\", "-").Replace(value)
+	})
+}
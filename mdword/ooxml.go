@@ -0,0 +1,186 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// rPrOrder and pPrOrder list run and paragraph properties in schema order. Word refuses to
+// open documents listing them in any other order.
+var (
+	rPrOrder = []string{
+		"w:rStyle", "w:rFonts", "w:b", "w:bCs", "w:i", "w:iCs", "w:caps", "w:smallCaps", "w:strike",
+		"w:dstrike", "w:outline", "w:shadow", "w:emboss", "w:imprint", "w:noProof", "w:snapToGrid",
+		"w:vanish", "w:webHidden", "w:color", "w:spacing", "w:w", "w:kern", "w:position", "w:sz",
+		"w:szCs", "w:highlight", "w:u", "w:effect", "w:bdr", "w:shd", "w:fitText", "w:vertAlign",
+		"w:rtl", "w:cs", "w:em", "w:lang", "w:eastAsianLayout", "w:specVanish", "w:oMath",
+	}
+	pPrOrder = []string{
+		"w:pStyle", "w:keepNext", "w:keepLines", "w:pageBreakBefore", "w:framePr", "w:widowControl",
+		"w:numPr", "w:suppressLineNumbers", "w:pBdr", "w:shd", "w:tabs", "w:suppressAutoHyphens",
+		"w:kinsoku", "w:wordWrap", "w:overflowPunct", "w:topLinePunct", "w:autoSpaceDE",
+		"w:autoSpaceDN", "w:bidi", "w:adjustRightInd", "w:snapToGrid", "w:spacing", "w:ind",
+		"w:contextualSpacing", "w:mirrorIndents", "w:suppressOverlap", "w:jc", "w:textDirection",
+		"w:textAlignment", "w:textboxTightWrap", "w:outlineLvl", "w:divId", "w:cnfStyle", "w:rPr",
+		"w:sectPr", "w:pPrChange",
+	}
+	sectPrOrder = []string{
+		"w:headerReference", "w:footerReference", "w:footnotePr", "w:endnotePr", "w:type", "w:pgSz",
+		"w:pgMar", "w:paperSrc", "w:pgBorders", "w:lnNumType", "w:pgNumType", "w:cols", "w:formProt",
+		"w:vAlign", "w:noEndnote", "w:titlePg", "w:textDirection", "w:bidi", "w:rtlGutter",
+		"w:docGrid", "w:printerSettings", "w:sectPrChange",
+	}
+)
+
+// setRunProps returns rPr with the given property elements set.
+func setRunProps(rPr string, elements ...string) string {
+	return setProps(rPr, "w:rPr", rPrOrder, elements)
+}
+
+// setParagraphProps returns pPr with the given property elements set.
+func setParagraphProps(pPr string, elements ...string) string {
+	return setProps(pPr, "w:pPr", pPrOrder, elements)
+}
+
+// setSectionProps returns sectPr with the given property elements set.
+func setSectionProps(sectPr string, elements ...string) string {
+	return setProps(sectPr, "w:sectPr", sectPrOrder, elements)
+}
+
+// setProps sets the elements inside the container, replacing elements of the same name and
+// keeping the children in the given schema order. An empty container is created if needed.
+func setProps(container, name string, order []string, elements []string) string {
+	if len(elements) == 0 {
+		return container
+	}
+	open, inner := "<"+name+">", ""
+	if container != "" {
+		openEnd := strings.Index(container, ">") + 1
+		open = container[:openEnd]
+		if strings.HasSuffix(open, "/>") {
+			open = strings.TrimSuffix(open, "/>") + ">"
+		} else {
+			inner = strings.TrimSuffix(container[openEnd:], "</"+name+">")
+		}
+	}
+
+	children := splitElements(inner)
+	for _, el := range elements {
+		replaced := false
+		for i, child := range children {
+			if elementName(child) == elementName(el) {
+				children[i] = el
+				replaced = true
+			}
+		}
+		if !replaced {
+			children = append(children, el)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(open)
+	for _, want := range order {
+		for _, child := range children {
+			if elementName(child) == want {
+				b.WriteString(child)
+			}
+		}
+	}
+	for _, child := range children {
+		if !contains(order, elementName(child)) {
+			b.WriteString(child)
+		}
+	}
+	b.WriteString("</" + name + ">")
+	return b.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// splitElements splits XML content into its top-level elements.
+func splitElements(s string) []string {
+	var elements []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '<' {
+			continue
+		}
+		end := strings.IndexByte(s[i:], '>')
+		if end < 0 {
+			break
+		}
+		tag := s[i : i+end+1]
+		switch {
+		case strings.HasPrefix(tag, "</"):
+			depth--
+		case strings.HasSuffix(tag, "/>"):
+		default:
+			if depth == 0 {
+				start = i
+			}
+			depth++
+			i += end
+			continue
+		}
+		if depth == 0 {
+			if strings.HasSuffix(tag, "/>") && !strings.HasPrefix(tag, "</") {
+				start = i
+			}
+			elements = append(elements, s[start:i+end+1])
+		}
+		i += end
+	}
+	return elements
+}
+
+// elementName returns the tag name of an element.
+func elementName(el string) string {
+	end := strings.IndexAny(el, " />")
+	if end < 1 {
+		return ""
+	}
+	return el[1:end]
+}
+
+// setAttr sets an attribute on the opening tag of el.
+func setAttr(el, attr, value string) string {
+	value = xmlEscaper.Replace(value)
+	re := regexp.MustCompile(`\s` + regexp.QuoteMeta(attr) + `="[^"]*"`)
+	openEnd := strings.Index(el, ">")
+	open := el[:openEnd]
+	if re.MatchString(open) {
+		return re.ReplaceAllLiteralString(open, " "+attr+`="`+value+`"`) + el[openEnd:]
+	}
+	if strings.HasSuffix(open, "/") {
+		return strings.TrimSuffix(open, "/") + " " + attr + `="` + value + `"/` + el[openEnd:]
+	}
+	return open + " " + attr + `="` + value + `"` + el[openEnd:]
+}
+
+// ensureNamespace declares a namespace prefix on the root element if it is missing.
+func ensureNamespace(doc, prefix, uri string) string {
+	if strings.Contains(doc, "xmlns:"+prefix+"=") {
+		return doc
+	}
+	root := strings.Index(doc, "<")
+	for root >= 0 && strings.HasPrefix(doc[root:], "<?") {
+		next := strings.Index(doc[root+1:], "<")
+		if next < 0 {
+			return doc
+		}
+		root += next + 1
+	}
+	if root < 0 {
+		return doc
+	}
+	end := root + strings.IndexAny(doc[root:], " >")
+	return doc[:end] + ` xmlns:` + prefix + `="` + uri + `"` + doc[end:]
+}
@@ -0,0 +1,173 @@
+package mdword
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// contentPartRegex matches the parts of a docx archive which may contain substituted values.
+var contentPartRegex = regexp.MustCompile(`^word/(document|header[0-9]*|footer[0-9]*)\.xml$`)
+
+// docxPackage is the raw zip content of a generated document. go-docx only gives access to the
+// document, header and footer parts, so anything beyond plain text replacement is done on the
+// written archive instead.
+type docxPackage struct {
+	names []string
+	parts map[string][]byte
+}
+
+func readPackage(b []byte) (*docxPackage, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read generated document: %w", err)
+	}
+
+	pkg := &docxPackage{parts: make(map[string][]byte)}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("unable to open %s: %w", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", f.Name, err)
+		}
+		pkg.names = append(pkg.names, f.Name)
+		pkg.parts[f.Name] = data
+	}
+	return pkg, nil
+}
+
+// contentParts returns the names of all parts which may hold substituted values.
+func (p *docxPackage) contentParts() []string {
+	var names []string
+	for _, name := range p.names {
+		if contentPartRegex.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// write writes the package as a zip archive compressed as the -compression option says.
+func (p *docxPackage) write(w io.Writer, compression string) error {
+	zw := zip.NewWriter(w)
+	method := zip.Deflate
+	switch compression {
+	case "store":
+		method = zip.Store
+	case "fast":
+		zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, flate.BestSpeed)
+		})
+	case "best":
+		zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, flate.BestCompression)
+		})
+	}
+	modified := time.Now()
+	for _, name := range p.names {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: modified})
+		if err != nil {
+			return fmt.Errorf("unable to create %s: %w", name, err)
+		}
+		if _, err := fw.Write(p.parts[name]); err != nil {
+			return fmt.Errorf("unable to write %s: %w", name, err)
+		}
+	}
+	return zw.Close()
+}
+
+func (p *docxPackage) writeFile(path, compression string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("unable to ensure path directories: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := p.write(f, compression); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Relationship types and content types of parts added to generated documents.
+const (
+	relTypeFooter     = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/footer"
+	contentTypeFooter = "application/vnd.openxmlformats-officedocument.wordprocessingml.footer+xml"
+)
+
+// addPart adds a new part to the package, registering its content type.
+func (p *docxPackage) addPart(name string, content []byte, contentType string) {
+	if _, exists := p.parts[name]; !exists {
+		p.names = append(p.names, name)
+	}
+	p.parts[name] = content
+
+	types := string(p.parts["[Content_Types].xml"])
+	override := `<Override PartName="/` + name + `" ContentType="` + contentType + `"/>`
+	if !strings.Contains(types, `PartName="/`+name+`"`) {
+		types = strings.Replace(types, "</Types>", override+"</Types>", 1)
+		p.parts["[Content_Types].xml"] = []byte(types)
+	}
+}
+
+// unusedPartName returns the first name of the form prefix<n>suffix not used in the package.
+func (p *docxPackage) unusedPartName(prefix, suffix string) string {
+	for n := 1; ; n++ {
+		name := prefix + strconv.Itoa(n) + suffix
+		if _, exists := p.parts[name]; !exists {
+			return name
+		}
+	}
+}
+
+// addRelationship adds a relationship from the main document part and returns its id.
+func (p *docxPackage) addRelationship(relType, target string) string {
+	rels := string(p.parts[relsName("word/document.xml")])
+	id := ""
+	for n := 1; ; n++ {
+		id = "rIdMdword" + strconv.Itoa(n)
+		if !strings.Contains(rels, `Id="`+id+`"`) {
+			break
+		}
+	}
+	p.addRelationshipFrom("word/document.xml", id, relType, target, false)
+	return id
+}
+
+// addRelationshipFrom adds a relationship with the given id from part, creating the
+// relationships part if needed. External targets are URLs rather than package parts.
+func (p *docxPackage) addRelationshipFrom(part, id, relType, target string, external bool) {
+	name := relsName(part)
+	rels, ok := p.parts[name]
+	if !ok {
+		p.names = append(p.names, name)
+		rels = []byte(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"></Relationships>`)
+	}
+	rel := `<Relationship Id="` + id + `" Type="` + relType + `" Target="` + xmlEscaper.Replace(target) + `"`
+	if external {
+		rel += ` TargetMode="External"`
+	}
+	rel += "/>"
+	p.parts[name] = []byte(strings.Replace(string(rels), "</Relationships>", rel+"</Relationships>", 1))
+}
+
+// relsName returns the name of the relationships part of part.
+func relsName(part string) string {
+	return path.Dir(part) + "/_rels/" + path.Base(part) + ".rels"
+}
@@ -0,0 +1,41 @@
+package mdword
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolvePath returns the local path of a file referenced from the input file source.
+// Forward slashes work on every platform and relative paths are resolved against the
+// directory of source. A percent-encoded path, as markdown link destinations often hold,
+// e.g. my%20diagram.png, is decoded unless a file of the encoded name exists.
+func resolvePath(path, source string) string {
+	resolve := func(p string) string {
+		p = filepath.FromSlash(p)
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(source), p)
+		}
+		return p
+	}
+	resolved := resolve(path)
+	if !strings.Contains(path, "%") {
+		return resolved
+	}
+	if fileExists(resolved) {
+		return resolved
+	}
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		if decoded := resolve(unescaped); fileExists(decoded) {
+			return decoded
+		}
+	}
+	return resolved
+}
+
+// fileExists reports whether a file exists at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
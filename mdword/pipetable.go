@@ -0,0 +1,96 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// tableSeparatorRegex matches the delimiter row of a pipe table, e.g. |:---|:---:|---:|.
+var tableSeparatorRegex = regexp.MustCompile(`^\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?$`)
+
+// isTableSeparator reports whether line is the delimiter row of a pipe table. A line of
+// dashes without any pipe is a rule rather than a table row.
+func isTableSeparator(line string) bool {
+	return strings.Contains(line, "|") && tableSeparatorRegex.MatchString(line)
+}
+
+// hasPipeTable reports whether text contains a pipe table.
+func hasPipeTable(text string) bool {
+	lines := strings.Split(text, "\n")
+	for i := range lines {
+		if pipeTableStart(lines, i) {
+			return true
+		}
+	}
+	return false
+}
+
+// pipeTableStart reports whether a GitHub style pipe table starts at lines[i]: a header row
+// followed by a delimiter row with the same number of cells. Anything else is left as text.
+func pipeTableStart(lines []string, i int) bool {
+	if i+1 >= len(lines) || !strings.Contains(lines[i], "|") || !isTableSeparator(lines[i+1]) {
+		return false
+	}
+	return len(tableRowCells(lines[i])) == len(tableRowCells(lines[i+1]))
+}
+
+// pipeTable lays out the pipe table starting at lines[i] and returns it with the number of
+// lines it takes. The body ends at the first line without a pipe. Body rows with fewer cells
+// than the header are padded, surplus cells are dropped.
+func pipeTable(lines []string, i int) (*table, int) {
+	header := tableRowCells(lines[i])
+	var aligns []string
+	for _, cell := range tableRowCells(lines[i+1]) {
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "right")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	t := &table{}
+	row := func(cells []string, isHeader bool) {
+		r := make([]tableCell, len(header))
+		for c := range r {
+			r[c] = tableCell{header: isHeader, colspan: 1, rowspan: 1, align: aligns[c]}
+			if c < len(cells) {
+				r[c].text = cells[c]
+			}
+		}
+		t.rows = append(t.rows, r)
+	}
+	row(header, true)
+	n := 2
+	for ; i+n < len(lines) && strings.Contains(lines[i+n], "|"); n++ {
+		row(tableRowCells(lines[i+n]), false)
+	}
+	return t, n
+}
+
+// tableRowCells splits a pipe table row into its trimmed cells. Leading and trailing pipes
+// are optional and \| is a literal pipe within a cell.
+func tableRowCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = strings.TrimSuffix(line, "|")
+	}
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
@@ -0,0 +1,33 @@
+package mdword
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// quoteRegex matches the > markers of a blockquote line, one per level of nesting.
+var quoteRegex = regexp.MustCompile(`(?m)^((?:>[ \t]?)+)`)
+
+// quoteIndent is the left indentation in twips added per level of blockquote nesting.
+const quoteIndent = 720
+
+// hasQuote reports whether text contains blockquote lines.
+func hasQuote(text string) bool {
+	return strings.Contains(text, ">") && quoteRegex.MatchString(text)
+}
+
+// quoteLevel returns the nesting level of a blockquote line and its text without the
+// markers, or 0 and the line itself if it is no blockquote line.
+func quoteLevel(line string) (int, string) {
+	m := quoteRegex.FindString(line)
+	if m == "" {
+		return 0, line
+	}
+	return strings.Count(m, ">"), line[len(m):]
+}
+
+// quoteProps returns pPr indented for a blockquote of the given level.
+func quoteProps(pPr string, level int) string {
+	return setParagraphProps(pPr, fmt.Sprintf(`<w:ind w:left="%d"/>`, level*quoteIndent))
+}
@@ -0,0 +1,107 @@
+package mdword
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/lukasjarosch/go-docx"
+)
+
+// WriteRedline fills the template with the values of newDoc, showing every word which
+// changed since the values of oldDoc as a tracked insertion or deletion.
+func (c *Converter) WriteRedline(templateFile string, oldDoc, newDoc *Document, outputFile string) error {
+	doc, placeholders, err := c.openTemplate(templateFile)
+	if err != nil {
+		return err
+	}
+	oldData, newData := oldDoc.Data, newDoc.Data
+	rend := &renderer{c: c, date: time.Now().UTC().Format(time.RFC3339), source: newDoc.Source, lang: c.opts.DocLang}
+	if rend.lang == "" {
+		rend.lang = newData[langKey]
+	}
+	rend.rtl = c.opts.RTL || strings.EqualFold(newData[dirKey], "rtl")
+	replaceMap := docx.PlaceholderMap{}
+	for key, newValue := range newData {
+		replaceMap[key] = rend.add([]block{&paragraph{runs: diffWords(oldData[key], newValue)}})
+	}
+	for key, oldValue := range oldData {
+		if _, ok := newData[key]; !ok {
+			replaceMap[key] = rend.add([]block{&paragraph{runs: diffWords(oldValue, "")}})
+		}
+	}
+	pkg, err := fillDocument(doc, placeholders, replaceMap, rend)
+	if err != nil {
+		return err
+	}
+	if err := pkg.writeFile(outputFile, c.opts.Compression); err != nil {
+		return fmt.Errorf("unable to write %s: %w", outputFile, err)
+	}
+	return nil
+}
+
+// diffWords computes a word level diff of two values, returned as runs of unchanged,
+// deleted and inserted text.
+func diffWords(oldValue, newValue string) []textRun {
+	a, b := splitWords(oldValue), splitWords(newValue)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var runs []textRun
+	push := func(text string, rev revision) {
+		if n := len(runs); n > 0 && runs[n-1].revision == rev {
+			runs[n-1].text += text
+			return
+		}
+		runs = append(runs, textRun{text: text, revision: rev})
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			push(a[i], unchanged)
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			push(a[i], deleted)
+			i++
+		default:
+			push(b[j], inserted)
+			j++
+		}
+	}
+	return runs
+}
+
+// splitWords splits s into alternating words and whitespace so that joining the result
+// yields s again.
+func splitWords(s string) []string {
+	var words []string
+	start := 0
+	space := false
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != space {
+			words = append(words, s[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
@@ -0,0 +1,688 @@
+package mdword
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// block is a piece of rendered value content which is written as OOXML in place of the
+// paragraph holding its placeholder.
+type block interface {
+	writeXML(b *strings.Builder, ctx *blockContext)
+}
+
+// blockContext carries the formatting of the placeholder being replaced so generated
+// content blends into the template.
+type blockContext struct {
+	pPr  string
+	rPr  string
+	rend *renderer
+}
+
+// revisionAuthor is recorded as the author of tracked changes.
+const revisionAuthor = "markdowntoword"
+
+// revisionTag opens a tracked change element with a document-unique id.
+func (ctx *blockContext) revisionTag(name string) string {
+	ctx.rend.revisions++
+	return fmt.Sprintf(`<%s w:id="%d" w:author="%s" w:date="%s">`, name, ctx.rend.revisions, revisionAuthor, ctx.rend.date)
+}
+
+type paragraph struct {
+	style string
+	runs  []textRun
+
+	// quote is the blockquote nesting level, which indents the paragraph.
+	quote int
+	// indent is the nesting level of a -toc entry, which indents it like a quote.
+	indent int
+}
+
+// revision marks a run as a tracked change.
+type revision int
+
+const (
+	unchanged revision = iota
+	inserted
+	deleted
+)
+
+// textRun is a stretch of text sharing the same formatting. Newlines become line breaks.
+type textRun struct {
+	text        string
+	revision    revision
+	checkbox    checkbox
+	columnBreak bool
+
+	// vertAlign is "subscript" or "superscript" for ~sub~ and ^sup^ spans.
+	vertAlign string
+	// color is the RRGGBB text color of a -allow-color span.
+	color     string
+	bold      bool
+	italic    bool
+	strike    bool
+	monospace bool
+
+	// comment is the text of a Word comment anchored to the run.
+	comment string
+	// link is the URL the run links to.
+	link string
+}
+
+// checkbox renders a run as a Word checkbox content control instead of text.
+type checkbox int
+
+const (
+	noCheckbox checkbox = iota
+	unchecked
+	checked
+)
+
+// monospaceFont is the run font of monospaced text.
+const monospaceFont = `<w:rFonts w:ascii="Courier New" w:hAnsi="Courier New" w:cs="Courier New"/>`
+
+const w14Namespace = "http://schemas.microsoft.com/office/word/2010/wordml"
+
+func (p *paragraph) writeXML(b *strings.Builder, ctx *blockContext) {
+	b.WriteString("<w:p>")
+	pPr := withStyle(ctx.pPr, p.style)
+	if p.quote > 0 {
+		pPr = quoteProps(pPr, p.quote)
+	}
+	if p.indent > 0 {
+		pPr = quoteProps(pPr, p.indent)
+	}
+	b.WriteString(pPr)
+	for _, run := range p.runs {
+		run.writeXML(b, ctx)
+	}
+	b.WriteString("</w:p>")
+}
+
+func (r textRun) writeXML(b *strings.Builder, ctx *blockContext) {
+	if r.checkbox != noCheckbox {
+		r.writeCheckbox(b, ctx)
+		return
+	}
+	if r.columnBreak {
+		b.WriteString("<w:r>" + ctx.rPr + `<w:br w:type="column"/></w:r>`)
+		return
+	}
+	commentID := ""
+	if r.comment != "" {
+		commentID = ctx.rend.addComment(r.comment)
+		b.WriteString(`<w:commentRangeStart w:id="` + commentID + `"/>`)
+	}
+	switch r.revision {
+	case inserted:
+		b.WriteString(ctx.revisionTag("w:ins"))
+	case deleted:
+		b.WriteString(ctx.revisionTag("w:del"))
+	}
+	textTag := "w:t"
+	if r.revision == deleted {
+		textTag = "w:delText"
+	}
+	var props []string
+	if r.monospace {
+		props = append(props, monospaceFont)
+	}
+	if r.bold {
+		props = append(props, "<w:b/>")
+	}
+	if r.italic {
+		props = append(props, "<w:i/>")
+	}
+	if r.strike {
+		props = append(props, "<w:strike/>")
+	}
+	if r.color != "" {
+		props = append(props, `<w:color w:val="`+r.color+`"/>`)
+	}
+	if r.vertAlign != "" {
+		props = append(props, `<w:vertAlign w:val="`+r.vertAlign+`"/>`)
+	}
+	if r.link != "" {
+		props = append(props, `<w:rStyle w:val="Hyperlink"/>`, `<w:u w:val="single"/>`)
+		if r.color == "" {
+			props = append(props, `<w:color w:val="`+linkColor+`"/>`)
+		}
+		b.WriteString(`<w:hyperlink r:id="` + ctx.rend.addLink(r.link) + `" w:history="1">`)
+	}
+	rPr := setRunProps(ctx.rPr, props...)
+	for i, line := range strings.Split(r.text, "\n") {
+		b.WriteString("<w:r>")
+		b.WriteString(rPr)
+		if i > 0 {
+			b.WriteString("<w:br/>")
+		}
+		if line != "" {
+			b.WriteString("<" + textTag + ` xml:space="preserve">`)
+			b.WriteString(textEscaper.Replace(line))
+			b.WriteString("</" + textTag + ">")
+		}
+		b.WriteString("</w:r>")
+	}
+	if r.link != "" {
+		b.WriteString("</w:hyperlink>")
+	}
+	switch r.revision {
+	case inserted:
+		b.WriteString("</w:ins>")
+	case deleted:
+		b.WriteString("</w:del>")
+	}
+	if commentID != "" {
+		b.WriteString(`<w:commentRangeEnd w:id="` + commentID + `"/><w:r>` + ctx.rPr + `<w:commentReference w:id="` + commentID + `"/></w:r>`)
+	}
+}
+
+func writeText(b *strings.Builder, text string) {
+	b.WriteString(`<w:t xml:space="preserve">`)
+	b.WriteString(textEscaper.Replace(text))
+	b.WriteString("</w:t>")
+}
+
+var xmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// braceUnescaper turns the escaped braces \{ and \} of values into literal ones. They stay
+// escaped until the text is written, so that no {{#if}}, {{table: …}} or {color:…} is
+// matched in them, and textEscaper does the same for text written as XML.
+var (
+	braceUnescaper = strings.NewReplacer(`\{`, "{", `\}`, "}")
+	textEscaper    = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", `\{`, "{", `\}`, "}")
+)
+
+// writeCheckbox writes a checkbox content control which can be toggled in Word.
+func (r textRun) writeCheckbox(b *strings.Builder, ctx *blockContext) {
+	val, glyph := "0", "☐"
+	if r.checkbox == checked {
+		val, glyph = "1", "☒"
+	}
+	font := `<w:rFonts w:ascii="MS Gothic" w:eastAsia="MS Gothic" w:hAnsi="MS Gothic" w:hint="eastAsia"/>`
+	b.WriteString(`<w:sdt><w:sdtPr><w14:checkbox><w14:checked w14:val="` + val + `"/>`)
+	b.WriteString(`<w14:checkedState w14:val="2612" w14:font="MS Gothic"/>`)
+	b.WriteString(`<w14:uncheckedState w14:val="2610" w14:font="MS Gothic"/></w14:checkbox></w:sdtPr>`)
+	b.WriteString(`<w:sdtContent><w:r>` + setRunProps(ctx.rPr, font))
+	writeText(b, glyph)
+	b.WriteString(`</w:r></w:sdtContent></w:sdt>`)
+}
+
+// taskRegexes returns the regexes matching values holding task list items and the task
+// list items themselves for list items marked with bullet.
+func taskRegexes(bullet string) (*regexp.Regexp, *regexp.Regexp) {
+	marker := regexp.QuoteMeta(bullet)
+	return regexp.MustCompile(`(?m)^` + marker + `\s*\[[ xX]\]\s`), regexp.MustCompile(`^` + marker + `\s*\[([ xX])\]\s`)
+}
+
+// listItemRuns returns the runs of a list item line, turning task list markers into
+// checkbox controls with -interactive-checkboxes.
+func (r *renderer) listItemRuns(line string) []textRun {
+	m := r.c.taskItemRegex.FindStringSubmatch(line)
+	if !r.c.opts.InteractiveCheckboxes || m == nil {
+		return []textRun{{text: line}}
+	}
+	state := unchecked
+	if m[1] != " " {
+		state = checked
+	}
+	return []textRun{{checkbox: state}, {text: " " + line[len(m[0]):]}}
+}
+
+// scriptRegex matches Pandoc style ~subscript~ and ^superscript^ spans. Double tildes are
+// matched as well so that those left over by unmatched strikethrough are not taken as spans.
+var scriptRegex = regexp.MustCompile(`~~|~([^~\s]+)~|\^([^^\s]+)\^`)
+
+// hasScripts reports whether text contains a subscript or superscript span.
+func hasScripts(text string) bool {
+	for _, m := range scriptRegex.FindAllStringSubmatchIndex(text, -1) {
+		if m[2] >= 0 || m[4] >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptRuns splits the plain text runs into runs of normal, subscript and superscript text.
+func scriptRuns(runs []textRun) []textRun {
+	var result []textRun
+	for _, run := range runs {
+		if run.checkbox != noCheckbox || run.columnBreak || run.monospace || run.vertAlign != "" {
+			result = append(result, run)
+			continue
+		}
+		last := 0
+		for _, m := range scriptRegex.FindAllStringSubmatchIndex(run.text, -1) {
+			span := run
+			switch {
+			case m[2] >= 0:
+				span.text, span.vertAlign = run.text[m[2]:m[3]], "subscript"
+			case m[4] >= 0:
+				span.text, span.vertAlign = run.text[m[4]:m[5]], "superscript"
+			default:
+				continue
+			}
+			if m[0] > last {
+				result = append(result, run.withText(run.text[last:m[0]]))
+			}
+			result = append(result, span)
+			last = m[1]
+		}
+		if last < len(run.text) || last == 0 {
+			result = append(result, run.withText(run.text[last:]))
+		}
+	}
+	return result
+}
+
+// withText returns a copy of the run with other text.
+func (r textRun) withText(text string) textRun {
+	r.text = text
+	return r
+}
+
+// withStyle sets the paragraph style of the given paragraph properties.
+func withStyle(pPr, style string) string {
+	if style == "" {
+		return pPr
+	}
+	return setParagraphProps(pPr, `<w:pStyle w:val="`+xmlEscaper.Replace(style)+`"/>`)
+}
+
+// textBlocks turns plain value text into paragraphs. Blank lines separate paragraphs and
+// list items and fenced code lines get paragraphs of their own so they can be styled.
+func (r *renderer) textBlocks(text string) []block {
+	opts := &r.c.opts
+	var blocks []block
+	var current *paragraph
+	var prose []*paragraph
+	inCode := false
+	lang := ""
+	var code []string
+	lines := strings.Split(strings.Trim(text, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "```"):
+			if inCode && opts.Highlight {
+				blocks = append(blocks, r.codeBlocks(lang, code)...)
+			}
+			lang, code = strings.TrimSpace(strings.TrimPrefix(line, "```")), nil
+			inCode = !inCode
+			current = nil
+		case inCode && opts.Highlight:
+			code = append(code, line)
+		case inCode:
+			blocks = append(blocks, &paragraph{style: opts.CodeStyle, runs: []textRun{{text: line, monospace: true}}})
+		case line == "":
+			current = nil
+		case thematicBreakRegex.MatchString(line):
+			if opts.HorizontalRule == "page" {
+				blocks = append(blocks, &pageBreak{})
+			}
+			current = nil
+		case pipeTableStart(lines, i):
+			t, n := pipeTable(lines, i)
+			blocks = append(blocks, t)
+			current = nil
+			i += n - 1
+		case imageLineRegex.MatchString(line):
+			m := imageLineRegex.FindStringSubmatch(line)
+			blocks = append(blocks, r.imageBlocks(m[1], m[2], m[3])...)
+			current = nil
+		case tableDirectiveRegex.MatchString(line):
+			blocks = append(blocks, r.csvTableBlock(tableDirectiveRegex.FindStringSubmatch(line)[1]))
+			current = nil
+		case columnBreakRegex.MatchString(line):
+			if len(blocks) > 0 {
+				if p, ok := blocks[len(blocks)-1].(*paragraph); ok {
+					p.runs = append(p.runs, textRun{columnBreak: true})
+					current = nil
+					continue
+				}
+			}
+			blocks = append(blocks, &paragraph{runs: []textRun{{columnBreak: true}}})
+			current = nil
+		case quoteRegex.MatchString(line):
+			level, text := quoteLevel(line)
+			if current == nil || current.quote != level || text == "" {
+				current = nil
+				if text == "" {
+					// an empty quote line separates the paragraphs of a quote
+					continue
+				}
+				current = &paragraph{style: opts.QuoteStyle, quote: level, runs: []textRun{{text: text, italic: opts.QuoteStyle == ""}}}
+				blocks = append(blocks, current)
+				prose = append(prose, current)
+				continue
+			}
+			current.runs[0].text += "\n" + text
+		case strings.HasPrefix(line, opts.Bullet) || orderedItemRegex.MatchString(line):
+			item := &paragraph{style: opts.ListStyle, runs: r.listItemRuns(line)}
+			blocks = append(blocks, item)
+			prose = append(prose, item)
+			current = nil
+		case current == nil || current.quote > 0:
+			current = &paragraph{style: opts.ParagraphStyle, runs: []textRun{{text: line}}}
+			blocks = append(blocks, current)
+			prose = append(prose, current)
+		default:
+			current.runs[0].text += "\n" + line
+		}
+	}
+	if inCode && opts.Highlight {
+		blocks = append(blocks, r.codeBlocks(lang, code)...)
+	}
+	for _, p := range prose {
+		if opts.CommentsAsWordComments {
+			p.runs = commentRuns(p.runs)
+		}
+		p.runs = codeRuns(p.runs)
+		p.runs = linkRuns(p.runs)
+		p.runs = inlineTagRuns(p.runs, r.c.logger)
+		p.runs = emphasisRuns(p.runs, opts.Math != "", opts.UnbalancedEmphasis, r.c.Warnf)
+		if opts.Math != "" {
+			p.runs = mathRuns(p.runs, opts.Math)
+		}
+		if opts.AllowColor {
+			p.runs = colorRuns(p.runs, r.c.Warnf)
+		}
+		p.runs = scriptRuns(p.runs)
+		if opts.SmartyPants {
+			p.runs = smartRuns(p.runs)
+		}
+	}
+	return blocks
+}
+
+// renderer keeps track of values which need more than plain text replacement. Such values
+// are substituted with a sentinel first and the sentinel's paragraph is swapped for the
+// rendered blocks once go-docx is done.
+type renderer struct {
+	c         *Converter
+	blocks    [][]block
+	revisions int
+	date      string
+
+	// vars and title are the document variables and the title set on the document, lang
+	// its language and rtl whether all values are written right to left.
+	vars  map[string]string
+	title string
+	lang  string
+	rtl   bool
+
+	// cover is the sentinel of the -cover page, empty without one.
+	cover string
+
+	// source is the input file of the document, relative table paths are resolved
+	// against its directory.
+	source string
+
+	// part is the name of the part being rendered and links holds the hyperlinks written.
+	part  string
+	links []hyperlink
+
+	// images holds the pictures written and drawings counts their placements.
+	images   []embeddedImage
+	drawings int
+
+	// comments holds the text of the Word comments written, numbered from commentBase.
+	comments    []string
+	commentBase int
+
+	// bodySectPr holds the final section properties of the part being processed and
+	// sections is set once a section break was written into it.
+	bodySectPr string
+	sections   bool
+}
+
+func (r *renderer) needsRendering(value string) bool {
+	opts := &r.c.opts
+	// go-docx turns every newline into a line break, blank lines need paragraphs of their own
+	return strings.Contains(value, "\n\n") || r.lang != "" || r.isRTL(value) || columnBreakRegex.MatchString(value) || hasScripts(value) || hasEmphasis(value) || opts.Math != "" && hasMath(value) || tableDirectiveRegex.MatchString(value) || strings.Contains(value, "|") && hasPipeTable(value) || hasCode(value) || hasLinks(value) || hasInlineTags(value) || hasQuote(value) || strings.Contains(value, "![") && imageLineRegex.MatchString(value) || thematicBreakRegex.MatchString(value) || opts.CommentsAsWordComments && htmlCommentRegex.MatchString(value) || opts.AllowColor && colorSpanRegex.MatchString(value) || opts.InteractiveCheckboxes && r.c.taskListRegex.MatchString(value) ||
+		opts.ParagraphStyle != "" || opts.ListStyle != "" || opts.CodeStyle != "" || opts.QuoteStyle != "" ||
+		opts.AllowHTMLTables && htmlTableRegex.MatchString(value) ||
+		opts.KeepTrailingBlank && strings.HasSuffix(value, "\n") ||
+		(opts.UnbalancedEmphasis == "autoclose" || opts.UnbalancedEmphasis == "warn") && hasUnclosedEmphasis(value)
+}
+
+// placeholder registers the rendered form of value and returns the sentinel to substitute.
+func (r *renderer) placeholder(value string) string {
+	opts := &r.c.opts
+	var blocks []block
+	if opts.AllowHTMLTables {
+		blocks = r.htmlTableBlocks(value)
+	} else {
+		blocks = r.textBlocks(value)
+	}
+	if opts.KeepTrailingBlank && strings.HasSuffix(value, "\n") {
+		blocks = append(blocks, &paragraph{})
+	}
+	if r.isRTL(value) {
+		for i, blk := range blocks {
+			blocks[i] = &rtlBlock{blk}
+		}
+	}
+	if opts.Columns > 1 && columnBreakRegex.MatchString(value) {
+		blocks = append(append([]block{&sectionBreak{}}, blocks...), &sectionBreak{columns: opts.Columns})
+	}
+	return r.add(blocks)
+}
+
+// add registers already rendered blocks and returns the sentinel to substitute.
+func (r *renderer) add(blocks []block) string {
+	r.blocks = append(r.blocks, blocks)
+	return sentinel(len(r.blocks) - 1)
+}
+
+func sentinel(i int) string {
+	return fmt.Sprintf("⟦mdword:%d⟧", i)
+}
+
+// apply replaces every registered sentinel in the given part.
+func (r *renderer) apply(part []byte) []byte {
+	xml := string(part)
+	r.bodySectPr, r.sections = "", false
+	if start := lastIndexTag(xml, "w:sectPr"); start >= 0 {
+		r.bodySectPr = leadingElement(xml[start:], "w:sectPr")
+	}
+	for i, blocks := range r.blocks {
+		s := sentinel(i)
+		for {
+			pos := strings.Index(xml, s)
+			if pos < 0 {
+				break
+			}
+			xml = r.replaceSentinelParagraph(xml, pos, s, blocks)
+		}
+	}
+	if r.sections {
+		// keep the content following the last column section on the same page
+		start := strings.LastIndex(xml, r.bodySectPr)
+		xml = xml[:start] + setSectionProps(r.bodySectPr, `<w:type w:val="continuous"/>`) + xml[start+len(r.bodySectPr):]
+	}
+	if strings.Contains(xml, "<w14:") {
+		xml = ensureNamespace(xml, "w14", w14Namespace)
+	}
+	if strings.Contains(xml, "<w:hyperlink r:id=") || strings.Contains(xml, "<a:blip r:embed=") {
+		xml = ensureNamespace(xml, "r", relNamespace)
+	}
+	if strings.Contains(xml, "<wp:inline ") {
+		xml = ensureNamespace(xml, "wp", wpNamespace)
+		xml = ensureNamespace(xml, "a", aNamespace)
+		xml = ensureNamespace(xml, "pic", picNamespace)
+	}
+	return []byte(xml)
+}
+
+// replaceSentinelParagraph swaps the paragraph holding the sentinel at pos for blocks. Any
+// template content sharing the paragraph is kept in paragraphs of its own before and after.
+func (r *renderer) replaceSentinelParagraph(xml string, pos int, s string, blocks []block) string {
+	pStart := lastIndexTag(xml[:pos], "w:p")
+	pEnd := strings.Index(xml[pos:], "</w:p>")
+	rStart := lastIndexTag(xml[:pos], "w:r")
+	rEnd := strings.Index(xml[pos:], "</w:r>")
+	if pStart < 0 || pEnd < 0 || rStart < pStart || rEnd < 0 {
+		// not inside a regular run, fall back to dropping the sentinel
+		return xml[:pos] + xml[pos+len(s):]
+	}
+	pEnd += pos + len("</w:p>")
+	rEnd += pos + len("</w:r>")
+
+	pOpenEnd := pStart + strings.Index(xml[pStart:], ">") + 1
+	pPr := leadingElement(xml[pOpenEnd:], "w:pPr")
+	rOpenEnd := rStart + strings.Index(xml[rStart:], ">") + 1
+	rPr := leadingElement(xml[rOpenEnd:], "w:rPr")
+
+	// split the sentinel's run into the content before and after it
+	runBefore := xml[rStart:pos]
+	runAfter := xml[pos+len(s) : rEnd]
+	before := xml[pOpenEnd+len(pPr):rStart] + closeRun(runBefore)
+	after := openRun(xml[rStart:rOpenEnd], rPr, runAfter) + xml[rEnd:pEnd-len("</w:p>")]
+
+	ctx := &blockContext{pPr: stripElement(pPr, "w:sectPr"), rPr: rPr, rend: r}
+	if r.lang != "" {
+		ctx.rPr = setRunProps(rPr, langElement(rPr, r.lang))
+	}
+	var b strings.Builder
+	b.WriteString(xml[:pStart])
+	if hasText(before) {
+		b.WriteString(xml[pStart:pOpenEnd] + ctx.pPr + before + "</w:p>")
+	}
+	for _, blk := range blocks {
+		blk.writeXML(&b, ctx)
+	}
+	if hasText(after) || pPr != ctx.pPr || !endsWithParagraph(blocks) {
+		b.WriteString(xml[pStart:pOpenEnd] + pPr + after + "</w:p>")
+	}
+	b.WriteString(xml[pEnd:])
+	return b.String()
+}
+
+// langKey is the data key whose value, e.g. from the front matter, is the document
+// language unless -doc-lang gives one.
+const langKey = "lang"
+
+// langElement returns the w:lang element of the given properties set to lang, keeping any
+// east Asian or complex script language already present.
+func langElement(props, lang string) string {
+	for _, el := range splitElements(props[strings.Index(props, ">")+1:]) {
+		if elementName(el) == "w:lang" {
+			return setAttr(el, "w:val", lang)
+		}
+	}
+	return `<w:lang w:val="` + xmlEscaper.Replace(lang) + `"/>`
+}
+
+// setDocumentLanguage sets the default language in the document defaults of the styles part.
+func (c *Converter) setDocumentLanguage(pkg *docxPackage, lang string) {
+	styles, ok := pkg.parts["word/styles.xml"]
+	if !ok {
+		c.Warnf("template has no styles part, the document default language is not set")
+		return
+	}
+	xml := string(styles)
+	if !strings.Contains(xml, "<w:docDefaults>") {
+		open := lastIndexTag(xml, "w:styles")
+		open += strings.Index(xml[open:], ">") + 1
+		xml = xml[:open] + "<w:docDefaults></w:docDefaults>" + xml[open:]
+	}
+	if !strings.Contains(xml, "<w:rPrDefault>") {
+		xml = strings.Replace(xml, "<w:docDefaults>", "<w:docDefaults><w:rPrDefault></w:rPrDefault>", 1)
+	}
+
+	start := strings.Index(xml, "<w:rPrDefault>") + len("<w:rPrDefault>")
+	end := strings.Index(xml, "</w:rPrDefault>")
+	rPr := leadingElement(xml[start:end], "w:rPr")
+	rPr = setRunProps(rPr, langElement(rPr, lang))
+	pkg.parts["word/styles.xml"] = []byte(xml[:start] + rPr + strings.TrimPrefix(xml[start:end], leadingElement(xml[start:end], "w:rPr")) + xml[end:])
+}
+
+// endsWithParagraph reports whether blocks can stand at the end of a body or table cell,
+// which must be closed by a paragraph.
+func endsWithParagraph(blocks []block) bool {
+	if len(blocks) == 0 {
+		return false
+	}
+	switch blk := blocks[len(blocks)-1].(type) {
+	case *paragraph, *sectionBreak, *pageBreak, *imageBlock:
+		return true
+	case *rtlBlock:
+		return endsWithParagraph([]block{blk.block})
+	}
+	return false
+}
+
+// closeRun terminates a run which was cut off right after the opening <w:t> of its text.
+func closeRun(run string) string {
+	return run + "</w:t></w:r>"
+}
+
+// openRun reopens a run cut off right before the closing </w:t> of its text.
+func openRun(open, rPr, rest string) string {
+	return open + rPr + `<w:t xml:space="preserve">` + rest
+}
+
+// hasText reports whether the paragraph content carries any visible text.
+func hasText(content string) bool {
+	for {
+		start := strings.Index(content, "<w:t")
+		if start < 0 {
+			return false
+		}
+		content = content[start:]
+		open := strings.Index(content, ">")
+		end := strings.Index(content, "</w:t>")
+		if open < 0 || end < 0 {
+			return false
+		}
+		if content[open-1] != '/' && open+1 < end {
+			return true
+		}
+		content = content[open+1:]
+	}
+}
+
+// lastIndexTag returns the position of the last opening tag with the given name, not
+// matching tags which merely share its prefix (e.g. w:pPr for w:p).
+func lastIndexTag(s, name string) int {
+	for end := len(s); end > 0; {
+		i := strings.LastIndex(s[:end], "<"+name)
+		if i < 0 {
+			return -1
+		}
+		next := i + len(name) + 1
+		if next < len(s) && (s[next] == '>' || s[next] == ' ') {
+			return i
+		}
+		end = i
+	}
+	return -1
+}
+
+// leadingElement returns the element with the given name if s starts with it.
+func leadingElement(s, name string) string {
+	if !strings.HasPrefix(s, "<"+name+">") && !strings.HasPrefix(s, "<"+name+" ") {
+		return ""
+	}
+	if end := strings.Index(s, "</"+name+">"); end >= 0 {
+		return s[:end+len(name)+3]
+	}
+	if end := strings.Index(s, "/>"); end >= 0 {
+		return s[:end+2]
+	}
+	return ""
+}
+
+// stripElement removes the first element with the given name from s.
+func stripElement(s, name string) string {
+	start := lastIndexTag(s, name)
+	if start < 0 {
+		return s
+	}
+	if end := strings.Index(s[start:], "</"+name+">"); end >= 0 {
+		return s[:start] + s[start+end+len(name)+3:]
+	}
+	return s
+}
@@ -0,0 +1,46 @@
+package mdword
+
+import (
+	"strings"
+	"unicode"
+)
+
+// rtlBlock writes a block with right-to-left paragraph and run direction.
+type rtlBlock struct {
+	block block
+}
+
+func (r *rtlBlock) writeXML(b *strings.Builder, ctx *blockContext) {
+	rtlCtx := *ctx
+	rtlCtx.pPr = setParagraphProps(ctx.pPr, "<w:bidi/>")
+	rtlCtx.rPr = setRunProps(ctx.rPr, "<w:rtl/>")
+	r.block.writeXML(b, &rtlCtx)
+}
+
+// rtlScripts are the scripts written right to left.
+var rtlScripts = []*unicode.RangeTable{unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko}
+
+// dirKey is the data key which, set to rtl, e.g. by "dir: rtl" in the front matter, writes
+// all values right to left like -rtl.
+const dirKey = "dir"
+
+// isRTL reports whether value is to be written right to left, either for all values with
+// -rtl or a dir: rtl key or because most of its letters belong to a right-to-left script.
+func (r *renderer) isRTL(value string) bool {
+	return r.rtl || rtlScript(value)
+}
+
+// rtlScript reports whether most letters of value belong to a right-to-left script.
+func rtlScript(value string) bool {
+	rtlLetters, letters := 0, 0
+	for _, r := range value {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		if unicode.IsOneOf(rtlScripts, r) {
+			rtlLetters++
+		}
+	}
+	return rtlLetters*2 > letters
+}
@@ -0,0 +1,17 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// thematicBreakRegex matches a horizontal rule line, three or more -, * or _ optionally
+// separated by spaces.
+var thematicBreakRegex = regexp.MustCompile(`(?m)^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+
+// pageBreak starts a new page, for horizontal rules with -horizontal-rule=page.
+type pageBreak struct{}
+
+func (*pageBreak) writeXML(b *strings.Builder, ctx *blockContext) {
+	b.WriteString("<w:p>" + ctx.pPr + `<w:r><w:br w:type="page"/></w:r></w:p>`)
+}
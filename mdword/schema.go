@@ -0,0 +1,75 @@
+package mdword
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"unicode/utf8"
+)
+
+// Schema is the subset of JSON Schema understood by -schema: required keys and the
+// pattern and length constraints of string values.
+type Schema struct {
+	Required   []string                  `json:"required"`
+	Properties map[string]propertySchema `json:"properties"`
+}
+
+type propertySchema struct {
+	Pattern   string `json:"pattern"`
+	MinLength *int   `json:"minLength"`
+	MaxLength *int   `json:"maxLength"`
+}
+
+// LoadSchema reads the JSON schema at path and checks its patterns compile.
+func LoadSchema(path string) (*Schema, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	schema := &Schema{}
+	if err := json.Unmarshal(content, schema); err != nil {
+		return nil, fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	for key, prop := range schema.Properties {
+		if _, err := regexp.Compile(prop.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern for %q in %s: %w", key, path, err)
+		}
+	}
+	return schema, nil
+}
+
+// Validate returns a description of every violation of the schema by data.
+func (s *Schema) Validate(data map[string]string) []string {
+	var violations []string
+	for _, key := range s.Required {
+		if _, ok := data[key]; !ok {
+			violations = append(violations, fmt.Sprintf("missing required key %q", key))
+		}
+	}
+
+	keys := make([]string, 0, len(s.Properties))
+	for key := range s.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := data[key]
+		if !ok {
+			continue
+		}
+		prop := s.Properties[key]
+		length := utf8.RuneCountInString(value)
+		if prop.Pattern != "" && !regexp.MustCompile(prop.Pattern).MatchString(value) {
+			violations = append(violations, fmt.Sprintf("value of %q does not match pattern %q", key, prop.Pattern))
+		}
+		if prop.MinLength != nil && length < *prop.MinLength {
+			violations = append(violations, fmt.Sprintf("value of %q is %d characters long, less than the minimum of %d", key, length, *prop.MinLength))
+		}
+		if prop.MaxLength != nil && length > *prop.MaxLength {
+			violations = append(violations, fmt.Sprintf("value of %q is %d characters long, more than the maximum of %d", key, length, *prop.MaxLength))
+		}
+	}
+	return violations
+}
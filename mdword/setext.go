@@ -0,0 +1,52 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// setextUnderlineRegex matches the line underlining a setext heading, === for level 1 and
+// --- for level 2.
+var setextUnderlineRegex = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+
+// setextHeadings rewrites setext headings, a line of text underlined with = or -, as the
+// ATX headings of the same level, from line skip on. The underline becomes blank so line
+// numbers stay the same. A --- after a blank line is a horizontal rule, and lines of lists,
+// quotes, tables, definitions and code are never taken as heading text.
+func setextHeadings(lines []string, skip int) {
+	inCode := false
+	for i := skip; i+1 < len(lines); i++ {
+		text := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(text, "```") {
+			inCode = !inCode
+			continue
+		}
+		if inCode || !isSetextText(text) {
+			continue
+		}
+		m := setextUnderlineRegex.FindStringSubmatch(lines[i+1])
+		if m == nil {
+			continue
+		}
+		level := "#"
+		if m[1][0] == '-' {
+			level = "##"
+		}
+		lines[i] = level + " " + text
+		lines[i+1] = ""
+		i++
+	}
+}
+
+// isSetextText reports whether a trimmed line can be the text of a setext heading.
+func isSetextText(text string) bool {
+	switch {
+	case text == "", headingLevel(text) > 0, setextUnderlineRegex.MatchString(text), thematicBreakRegex.MatchString(text):
+		return false
+	case strings.HasPrefix(text, "- "), strings.HasPrefix(text, "* "), strings.HasPrefix(text, "+ "),
+		orderedItemRegex.MatchString(text), strings.HasPrefix(text, ">"), strings.HasPrefix(text, ":"),
+		strings.HasPrefix(text, "|"):
+		return false
+	}
+	return true
+}
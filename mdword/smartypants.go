@@ -0,0 +1,68 @@
+package mdword
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// smartPunctuation replaces the straight quotes of text with curly ones, -- with an en dash,
+// --- with an em dash and ... with an ellipsis, for -smartypants. prev is the character
+// before text, 0 at the start of a paragraph; a quote opens after a space or opening
+// punctuation and closes otherwise, so the apostrophe of "don't" is a closing quote.
+func smartPunctuation(text string, prev rune) string {
+	if !strings.ContainsAny(text, `"'-.`) {
+		return text
+	}
+	var b strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '-' && i+2 < len(runes) && runes[i+1] == '-' && runes[i+2] == '-':
+			r = '—'
+			i += 2
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			r = '–'
+			i++
+		case r == '.' && i+2 < len(runes) && runes[i+1] == '.' && runes[i+2] == '.':
+			r = '…'
+			i += 2
+		case r == '"' && opensQuote(prev):
+			r = '“'
+		case r == '"':
+			r = '”'
+		case r == '\'' && opensQuote(prev):
+			r = '‘'
+		case r == '\'':
+			r = '’'
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+// opensQuote reports whether a quote following the character prev opens a quotation.
+func opensQuote(prev rune) bool {
+	return prev == 0 || unicode.IsSpace(prev) || strings.ContainsRune("([{<–—“‘", prev)
+}
+
+// smartRuns applies smartPunctuation to the text runs of a paragraph. Code is kept
+// verbatim, but its last character still decides whether a following quote opens.
+func smartRuns(runs []textRun) []textRun {
+	var prev rune
+	for i, run := range runs {
+		if run.checkbox != noCheckbox || run.columnBreak {
+			prev = ' '
+			continue
+		}
+		if !run.monospace {
+			runs[i].text = smartPunctuation(run.text, prev)
+		}
+		if r, _ := utf8.DecodeLastRuneInString(runs[i].text); r != utf8.RuneError {
+			prev = r
+		}
+	}
+	return runs
+}
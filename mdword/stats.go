@@ -0,0 +1,45 @@
+package mdword
+
+import (
+	"strings"
+	"unicode"
+)
+
+// filledWords returns the number of words of the values filling the placeholders, each
+// value counted once.
+func filledWords(placeholders []string, data map[string]string) int {
+	seen := make(map[string]bool)
+	words := 0
+	for _, key := range placeholders {
+		value, ok := data[key]
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		words += wordCount(value)
+	}
+	return words
+}
+
+// wordCount counts the words of a value as it reads in the document: code, link and
+// emphasis markup is stripped and markers such as bullets, quotes and table pipes, which
+// contain no letter or digit, are no words.
+func wordCount(value string) int {
+	words := 0
+	for _, field := range strings.Fields(plainText(value)) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0 {
+			words++
+		}
+	}
+	return words
+}
+
+// plainText returns the text of a value as it reads in the document, without code, link
+// and emphasis markup.
+func plainText(value string) string {
+	var text strings.Builder
+	for _, run := range emphasisRuns(linkRuns(codeRuns([]textRun{{text: value}})), false, "", nil) {
+		text.WriteString(run.text)
+	}
+	return text.String()
+}
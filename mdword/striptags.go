@@ -0,0 +1,59 @@
+package mdword
+
+import (
+	"regexp"
+	"strings"
+)
+
+// stripTags removes the elements with the given names, including their content, from the
+// markdown. Nested elements of the same name are removed along with their outermost
+// parent and elements spanning whole lines take their line breaks with them.
+func (c *Converter) stripTags(markdown string, names []string) string {
+	for _, name := range names {
+		markdown = c.stripTag(markdown, name)
+	}
+	return markdown
+}
+
+func (c *Converter) stripTag(s, name string) string {
+	tagRegex := regexp.MustCompile(`(?i)<(/?)` + regexp.QuoteMeta(name) + `(?:\s[^>]*)?(/?)>`)
+
+	var out strings.Builder
+	depth := 0
+	start := 0
+	last := 0
+	for _, m := range tagRegex.FindAllStringSubmatchIndex(s, -1) {
+		closing := m[3] > m[2]
+		selfClosing := m[5] > m[4]
+		switch {
+		case selfClosing && depth == 0:
+			out.WriteString(s[last:m[0]])
+			last = wholeLineEnd(s, m[0], m[1])
+		case selfClosing:
+		case !closing:
+			if depth == 0 {
+				start = m[0]
+			}
+			depth++
+		case depth > 0:
+			depth--
+			if depth == 0 {
+				out.WriteString(s[last:start])
+				last = wholeLineEnd(s, start, m[1])
+			}
+		}
+	}
+	if depth > 0 {
+		c.Warnf("unclosed <%s> element, leaving it in place", name)
+	}
+	out.WriteString(s[last:])
+	return out.String()
+}
+
+// wholeLineEnd extends end past the line break if s[start:end] makes up entire lines.
+func wholeLineEnd(s string, start, end int) int {
+	if (start == 0 || s[start-1] == '\n') && end < len(s) && s[end] == '\n' {
+		return end + 1
+	}
+	return end
+}
@@ -0,0 +1,34 @@
+package mdword
+
+import "strings"
+
+// tocKey is the placeholder filled with the outline of the markdown headings with -toc,
+// unless the data defines it explicitly.
+const tocKey = "toc"
+
+// tocEntry is a second-level or deeper heading of the markdown.
+type tocEntry struct {
+	level int
+	title string
+}
+
+// headingTitle returns the text of an ATX heading line without its markers. Closing #s are
+// only dropped after a space, so that "### C#" keeps its title.
+func headingTitle(line string, level int) string {
+	title := strings.TrimSpace(line[level:])
+	if trimmed := strings.TrimRight(title, "#"); trimmed == "" || strings.HasSuffix(trimmed, " ") {
+		title = strings.TrimSpace(trimmed)
+	}
+	return title
+}
+
+// tocBlocks renders the headings as a paragraph per heading, indented by its nesting below
+// the second level. Page numbers are left out, as only Word knows them.
+func (r *renderer) tocBlocks(entries []tocEntry) []block {
+	blocks := make([]block, 0, len(entries))
+	for _, entry := range entries {
+		runs := emphasisRuns(codeRuns([]textRun{{text: entry.title}}), false, "", nil)
+		blocks = append(blocks, &paragraph{style: r.c.opts.ParagraphStyle, runs: runs, indent: entry.level - 2})
+	}
+	return blocks
+}
@@ -0,0 +1,61 @@
+package mdword
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// truncatedPlaceholderRegex matches placeholder keys with a length suffix, e.g. summary:80.
+var truncatedPlaceholderRegex = regexp.MustCompile(`^(.+):(\d+)$`)
+
+// addTruncatedValues returns a copy of data with a value added for every key:N placeholder
+// of the template whose key is in data, holding at most N characters of the value. data
+// itself is left alone, it may be the caller's map.
+func addTruncatedValues(data map[string]string, placeholders []string) map[string]string {
+	result := make(map[string]string, len(data))
+	for key, value := range data {
+		result[key] = value
+	}
+	for _, placeholder := range placeholders {
+		m := truncatedPlaceholderRegex.FindStringSubmatch(placeholder)
+		if m == nil {
+			continue
+		}
+		value, ok := data[m[1]]
+		if !ok {
+			continue
+		}
+		limit, err := strconv.Atoi(m[2])
+		if err != nil {
+			continue
+		}
+		result[placeholder] = truncate(value, limit)
+	}
+	return result
+}
+
+// markupEscaper escapes the characters of plain text which would otherwise be read as
+// emphasis markup.
+var markupEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, `#`, `\#`)
+
+// truncate shortens the text of value as it reads in the document to at most limit runes,
+// cutting at the last word boundary and appending an ellipsis. A shortened value loses its
+// code, link and emphasis markup, which could otherwise be cut between its delimiters and
+// leak into the text. Values reading within the limit are returned unchanged.
+func truncate(value string, limit int) string {
+	runes := []rune(plainText(value))
+	if len(runes) <= limit {
+		return value
+	}
+	cut := string(runes[:limit])
+	if !unicode.IsSpace(runes[limit]) {
+		if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	return markupEscaper.Replace(strings.TrimRightFunc(cut, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})) + "…"
+}
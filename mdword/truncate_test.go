@@ -0,0 +1,29 @@
+package mdword
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name, value string
+		limit       int
+		want        string
+	}{
+		{"under the limit", "short value", 20, "short value"},
+		{"at the limit", "exactly ten", 11, "exactly ten"},
+		{"word boundary", "the quick brown fox", 12, "the quick…"},
+		{"cut at a space", "the quick brown fox", 9, "the quick…"},
+		{"trailing punctuation dropped", "first, second", 8, "first…"},
+		{"markup within the limit kept", "**bold** text", 9, "**bold** text"},
+		{"bold cut open", "a **bold statement** follows", 10, "a bold…"},
+		{"link text kept", "see [the manual](https://example.com/manual) first", 14, "see the manual…"},
+		{"code cut open", "run `go test ./...` now", 11, "run go test…"},
+		{"literal asterisks escaped", `5 \* 3 is fifteen`, 10, `5 \* 3 is…`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.value, tt.limit); got != tt.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tt.value, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	// metricsFile receives the -metrics counters when the run ends.
+	metricsFile string
+	startTime   = time.Now()
+)
+
+// exit ends the run with the given status, writing the metrics first.
+func exit(code int) {
+	flushMetrics()
+	os.Exit(code)
+}
+
+func flushMetrics() {
+	if metricsFile == "" {
+		return
+	}
+	if err := writeMetrics(metricsFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: unable to write metrics: %v\n", err)
+	}
+}
+
+// writeMetrics writes the counters of the run in the Prometheus text exposition format.
+func writeMetrics(path string) error {
+	t := runStats()
+	metrics := []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"markdowntoword_files_processed_total", "counter", "Documents written.", float64(t.Documents)},
+		{"markdowntoword_placeholders_replaced_total", "counter", "Template placeholders filled with a value.", float64(t.Placeholders)},
+		{"markdowntoword_warnings_total", "counter", "Warnings reported.", float64(t.Warnings)},
+		{"markdowntoword_errors_total", "counter", "Errors reported.", float64(t.Errors)},
+		{"markdowntoword_duration_seconds", "gauge", "Duration of the run.", time.Since(startTime).Seconds()},
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		fmt.Fprintf(f, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", m.name, m.help, m.name, m.kind, m.name, strconv.FormatFloat(m.value, 'f', -1, 64))
+	}
+	return f.Close()
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localPath returns the file path given by a flag, cleaned. A file:// URL, as copied from a
+// browser or file manager, becomes the local path it points to with its percent-encoding
+// decoded. The empty path and - for stdin or stdout are kept.
+func localPath(path string) string {
+	if path == "" || path == "-" {
+		return path
+	}
+	if strings.HasPrefix(path, "file://") {
+		if u, err := url.Parse(path); err == nil && u.Path != "" {
+			p := u.Path
+			// file:///C:/docs/spec.md holds a Windows path with a drive letter
+			if filepath.VolumeName(filepath.FromSlash(p[1:])) != "" {
+				p = p[1:]
+			}
+			path = filepath.FromSlash(p)
+		}
+	}
+	return filepath.Clean(path)
+}
+
+// withExt returns path with its extension replaced by ext. Only a real extension is
+// replaced, the name of a dotfile like .spec is kept whole.
+func withExt(path, ext string) string {
+	base := filepath.Base(path)
+	old := filepath.Ext(base)
+	if old == base {
+		old = ""
+	}
+	return strings.TrimSuffix(path, old) + ext
+}
+
+// defaultOutput returns the path of the document converted from input when no -output is
+// given: input with the extension ext, in dir if it is set and next to input otherwise.
+func defaultOutput(input, dir, ext string) string {
+	output := withExt(input, ext)
+	if dir != "" {
+		output = filepath.Join(dir, filepath.Base(output))
+	}
+	return output
+}
+
+// checkNotInput returns an error if output is one of the input files, which writing the
+// output would destroy.
+func checkNotInput(output string, inputs ...string) error {
+	if output == "-" {
+		return nil
+	}
+	outInfo, outErr := os.Stat(output)
+	outAbs, _ := filepath.Abs(output)
+	for _, input := range inputs {
+		if input == "" || input == "-" {
+			continue
+		}
+		inAbs, _ := filepath.Abs(input)
+		same := outAbs == inAbs
+		if inInfo, err := os.Stat(input); err == nil && outErr == nil {
+			same = same || os.SameFile(outInfo, inInfo)
+		}
+		if same {
+			return fmt.Errorf("output %s would overwrite the input file %s", output, input)
+		}
+	}
+	return nil
+}
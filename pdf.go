@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// pdfConverters are the LibreOffice commands looked up on PATH to convert documents to PDF.
+var pdfConverters = []string{"soffice", "libreoffice"}
+
+// findPDFConverter returns the path of the first PDF converter on PATH.
+func findPDFConverter() (string, error) {
+	for _, name := range pdfConverters {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("PDF output needs LibreOffice, install it so that soffice or libreoffice is on PATH")
+}
+
+// convertToPDF converts the document at docxPath with converter into a PDF file of the same
+// name next to it and returns the PDF's path.
+func convertToPDF(converter, docxPath string) (string, error) {
+	dir := filepath.Dir(docxPath)
+	cmd := exec.Command(converter, "--headless", "--convert-to", "pdf", "--outdir", dir, docxPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("unable to convert %s to PDF: %v: %s", docxPath, err, strings.TrimSpace(stderr.String()))
+	}
+	return withExt(docxPath, ".pdf"), nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/lunchboxer/markdowntoword/mdword"
+)
+
+// selfTestMarkdown exercises the bullet rewriting, which once wrote a double-encoded "•".
+const selfTestMarkdown = "## Self Test\n### List\n- first\n+ second\n"
+
+var selfTestWant = []string{"• first", "• second"}
+
+var textElementRegex = regexp.MustCompile(`<w:t(?:\s[^>]*)?>([^<]*)</w:t>`)
+
+// runSelfTest round-trips a small markdown document through the full conversion using a
+// generated template and checks the bullets of the output.
+func runSelfTest(conv *mdword.Converter) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("conversion failed: %v", r)
+		}
+	}()
+
+	dir, err := os.MkdirTemp("", "markdowntoword-selftest")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	markdownFile := filepath.Join(dir, "selftest.md")
+	templateFile := filepath.Join(dir, "template.docx")
+	outputFile := filepath.Join(dir, "selftest.docx")
+	if err := os.WriteFile(markdownFile, []byte(selfTestMarkdown), 0644); err != nil {
+		return err
+	}
+	if err := writeSelfTestTemplate(templateFile, conv.Placeholder("self-test-list")); err != nil {
+		return err
+	}
+
+	doc, err := conv.ParseMarkdownFile(markdownFile)
+	if err != nil {
+		return err
+	}
+	if err := conv.RenderFile(doc, templateFile, outputFile); err != nil {
+		return err
+	}
+
+	text, err := documentText(outputFile)
+	if err != nil {
+		return err
+	}
+	for _, want := range selfTestWant {
+		if !strings.Contains(text, want) {
+			return fmt.Errorf("output text %q does not contain %q", text, want)
+		}
+	}
+	return nil
+}
+
+// writeSelfTestTemplate writes a minimal docx with a single paragraph of text.
+func writeSelfTestTemplate(path, text string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(f)
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>` +
+			`</Relationships>`},
+		{"word/document.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+			`<w:p><w:r><w:t>` + html.EscapeString(text) + `</w:t></w:r></w:p>` +
+			`</w:body></w:document>`},
+	}
+	for _, part := range parts {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// documentText extracts the plain text of the main document part of a docx file.
+func documentText(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	f, err := zr.Open("word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	document, err := io.ReadAll(f)
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, m := range textElementRegex.FindAllStringSubmatch(string(document), -1) {
+		text.WriteString(html.UnescapeString(m[1]))
+	}
+	return text.String(), nil
+}